@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+)
+
+// AdaptiveSettings configures a computerized adaptive quiz. Questions are
+// chosen one at a time to be most informative at the player's current ability
+// estimate until the estimate's standard error drops below StandardError or
+// MaxQuestions have been asked.
+type AdaptiveSettings struct {
+	StandardError float64 `json:"standard_error"`
+	MaxQuestions  int     `json:"max_questions"`
+}
+
+// validate checks that the adaptive stopping rules are usable
+func (a *AdaptiveSettings) validate() error {
+	if a.StandardError <= 0 || a.StandardError >= 1 {
+		return fmt.Errorf("adaptive: standard_error must be between 0 and 1, got %v", a.StandardError)
+	}
+	if a.MaxQuestions < 0 {
+		return fmt.Errorf("adaptive: max_questions must not be negative")
+	}
+	return nil
+}
+
+// abilityGrid is the range and resolution of abilities considered when
+// estimating a player's ability, in logits
+const (
+	abilityMin  = -4.0
+	abilityMax  = 4.0
+	abilityStep = 0.05
+)
+
+// discrimination returns the question's IRT discrimination, treating an unset
+// value as the Rasch model's 1
+func (q Question) discrimination() float64 {
+	if q.Discrimination == 0 {
+		return 1
+	}
+	return q.Discrimination
+}
+
+// probabilityCorrect is the two-parameter logistic model: the chance a player
+// of ability theta answers q correctly
+func probabilityCorrect(q Question, theta float64) float64 {
+	return 1 / (1 + math.Exp(-q.discrimination()*(theta-q.Difficulty)))
+}
+
+// itemInformation is the Fisher information q provides at ability theta
+func itemInformation(q Question, theta float64) float64 {
+	p := probabilityCorrect(q, theta)
+	a := q.discrimination()
+	return a * a * p * (1 - p)
+}
+
+// estimateAbility returns the expected a posteriori ability estimate and its
+// standard error given the questions asked and whether each was answered
+// correctly. A standard normal prior keeps the estimate finite when every
+// answer so far is right or wrong.
+func estimateAbility(questions []Question, correct []bool) (theta, se float64) {
+	var total, mean, meanSq float64
+	for t := abilityMin; t <= abilityMax+abilityStep/2; t += abilityStep {
+		weight := math.Exp(-t * t / 2)
+		for i, q := range questions {
+			p := probabilityCorrect(q, t)
+			if correct[i] {
+				weight *= p
+			} else {
+				weight *= 1 - p
+			}
+		}
+		total += weight
+		mean += weight * t
+		meanSq += weight * t * t
+	}
+
+	mean /= total
+	variance := meanSq/total - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+	return mean, math.Sqrt(variance)
+}
+
+// mostInformative returns the index in pool of the unasked question with the
+// highest information at theta, or -1 if every question has been asked
+func mostInformative(pool []Question, asked map[int]bool, theta float64) int {
+	best, bestInfo := -1, -1.0
+	for i, q := range pool {
+		if asked[q.ID] {
+			continue
+		}
+		if info := itemInformation(q, theta); info > bestInfo {
+			best, bestInfo = i, info
+		}
+	}
+	return best
+}
+
+// advanceAdaptive re-estimates the player's ability from the answers so far
+// and queues the most informative remaining question, unless a stopping rule
+// has been met. Callers must hold sessionMux if the session is shared.
+func (s *QuizSession) advanceAdaptive() {
+	correct := make([]bool, len(s.Answers))
+	asked := make(map[int]bool, len(s.Answers))
+	for i, a := range s.Answers {
+		correct[i] = a.Correct
+		asked[a.QuestionID] = true
+	}
+	s.Ability, s.AbilitySE = estimateAbility(s.Questions[:len(s.Answers)], correct)
+
+	if s.AbilitySE < s.Adaptive.StandardError {
+		return
+	}
+	if s.Adaptive.MaxQuestions > 0 && len(s.Questions) >= s.Adaptive.MaxQuestions {
+		return
+	}
+
+	// A template that fails to produce a variant is skipped in favour of
+	// the next most informative question
+	for next := mostInformative(s.adaptivePool, asked, s.Ability); next >= 0; next = mostInformative(s.adaptivePool, asked, s.Ability) {
+		q, err := s.instantiate(s.adaptivePool[next])
+		if err == nil {
+			s.Questions = append(s.Questions, q)
+			return
+		}
+		log.Printf("Error generating variant: %v", err)
+		asked[q.ID] = true
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimateAbility(t *testing.T) {
+	questions := []Question{
+		{ID: 1, Difficulty: -1},
+		{ID: 2, Difficulty: 0},
+		{ID: 3, Difficulty: 1},
+	}
+
+	theta, se := estimateAbility(nil, nil)
+	if math.Abs(theta) > 1e-6 || math.Abs(se-1) > 0.01 {
+		t.Errorf("prior estimate = %v ± %v, want 0 ± 1", theta, se)
+	}
+
+	high, highSE := estimateAbility(questions, []bool{true, true, true})
+	low, _ := estimateAbility(questions, []bool{false, false, false})
+	if high <= 0 || low >= 0 {
+		t.Errorf("all correct should estimate above 0 and all wrong below 0, got %v and %v", high, low)
+	}
+	if highSE >= 1 {
+		t.Errorf("answers should shrink the standard error below the prior, got %v", highSE)
+	}
+}
+
+func TestMostInformativePicksNearestDifficulty(t *testing.T) {
+	pool := []Question{
+		{ID: 1, Difficulty: -2},
+		{ID: 2, Difficulty: 0.2},
+		{ID: 3, Difficulty: 2},
+	}
+
+	if got := mostInformative(pool, nil, 0); got != 1 {
+		t.Errorf("mostInformative(theta=0) = %d, want 1", got)
+	}
+	if got := mostInformative(pool, map[int]bool{2: true}, 1.5); got != 2 {
+		t.Errorf("mostInformative(theta=1.5, asked=2) = %d, want 2", got)
+	}
+	if got := mostInformative(pool, map[int]bool{1: true, 2: true, 3: true}, 0); got != -1 {
+		t.Errorf("mostInformative with every question asked = %d, want -1", got)
+	}
+}
+
+func TestAdaptiveSessionStopsAtMaxQuestions(t *testing.T) {
+	var pool []Question
+	for i := 1; i <= 10; i++ {
+		pool = append(pool, Question{
+			ID:          i,
+			Question:    "Q?",
+			Choices:     []string{"A", "B"},
+			AnswerIndex: 0,
+			Difficulty:  float64(i-5) / 2,
+		})
+	}
+
+	session := newTestSession(t, []Question{pool[mostInformative(pool, nil, 0)]})
+	session.Adaptive = &AdaptiveSettings{StandardError: 0.01, MaxQuestions: 4}
+	session.adaptivePool = pool
+
+	served := map[int]bool{}
+	for i := 0; !session.Finished(); i++ {
+		if i > len(pool) {
+			t.Fatal("adaptive session did not stop")
+		}
+		q := session.Questions[session.Current]
+		if served[q.ID] {
+			t.Fatalf("question %d served twice", q.ID)
+		}
+		served[q.ID] = true
+		postAnswer(session.ID, session.Current, 0)
+	}
+
+	if len(session.Questions) != 4 {
+		t.Errorf("adaptive session asked %d questions, want 4", len(session.Questions))
+	}
+	if session.Ability <= 0 {
+		t.Errorf("all correct answers should raise the ability estimate, got %v", session.Ability)
+	}
+}
+
+func TestAdaptiveSessionStopsAtStandardError(t *testing.T) {
+	var pool []Question
+	for i := 1; i <= 50; i++ {
+		pool = append(pool, Question{ID: i, Question: "Q?", Choices: []string{"A", "B"}, AnswerIndex: 0, Discrimination: 2})
+	}
+
+	session := newTestSession(t, []Question{pool[0]})
+	session.Adaptive = &AdaptiveSettings{StandardError: 0.5}
+	session.adaptivePool = pool
+
+	for !session.Finished() {
+		choice := session.Current % 2
+		postAnswer(session.ID, session.Current, choice)
+	}
+
+	if session.AbilitySE >= 0.5 {
+		t.Errorf("session stopped with standard error %v, want below 0.5", session.AbilitySE)
+	}
+	if len(session.Questions) == len(pool) {
+		t.Errorf("session should stop before exhausting the pool")
+	}
+}
+
+func TestAdaptiveSessionSkipsBrokenTemplate(t *testing.T) {
+	broken := multiplyTemplate()
+	broken.ID = 2
+	broken.Template.Answer = "a / (b - b)"
+	pool := []Question{
+		{ID: 1, Question: "Q1?", Choices: []string{"A", "B"}},
+		broken,
+		{ID: 3, Question: "Q3?", Choices: []string{"A", "B"}, Difficulty: 4},
+	}
+
+	session := newTestSession(t, []Question{pool[0]})
+	session.Adaptive = &AdaptiveSettings{StandardError: 0.01, MaxQuestions: 2}
+	session.adaptivePool = pool
+
+	postAnswer(session.ID, 0, 0)
+	if len(session.Questions) != 2 || session.Questions[1].ID != 3 {
+		t.Errorf("after the first answer the session has %+v, want question 3 next", session.Questions)
+	}
+}
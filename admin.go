@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// adminTokenField is the form field admin forms carry their token in
+const adminTokenField = "csrf_token"
+
+// adminTokenLifetime is how long an admin page's form token stays valid
+const adminTokenLifetime = 12 * time.Hour
+
+// requireAdmin wraps an admin handler with HTTP basic authentication against
+// the ADMIN_USER (default "admin") and ADMIN_PASSWORD environment variables.
+// Admin pages are disabled entirely when no password is configured.
+// Browsers send basic auth credentials with any request to the site, so a
+// POST must also carry the form token the admin page it came from was given.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		wantPassword := os.Getenv("ADMIN_PASSWORD")
+		if wantPassword == "" {
+			http.Error(w, "Admin access is not configured", http.StatusForbidden)
+			return
+		}
+		wantUser := os.Getenv("ADMIN_USER")
+		if wantUser == "" {
+			wantUser = "admin"
+		}
+
+		user, password, ok := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(wantUser)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(wantPassword)) == 1
+		if !ok || !userOK || !passwordOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="quiz admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodPost && !verifyAdminToken(user, r.URL.Path, r.FormValue(adminTokenField), time.Now()) {
+			http.Error(w, "Invalid or expired form token; reload the page and try again", http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// adminToken signs a token allowing user to post to the admin path action.
+// The token is the issue time and an HMAC of it, so it expires without
+// being stored.
+func adminToken(user, action string, issued time.Time) string {
+	ts := strconv.FormatInt(issued.Unix(), 10)
+	h := hmac.New(sha256.New, []byte(hmacSecret))
+	h.Write([]byte("admin:" + user + ":" + action + ":" + ts))
+	return ts + "." + base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// verifyAdminToken reports whether token is an unexpired adminToken for the
+// user and action
+func verifyAdminToken(user, action, token string, now time.Time) bool {
+	ts, _, ok := strings.Cut(token, ".")
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if !ok || err != nil {
+		return false
+	}
+	issued := time.Unix(unix, 0)
+	if now.Sub(issued) > adminTokenLifetime || issued.After(now.Add(time.Minute)) {
+		return false
+	}
+	return hmac.Equal([]byte(adminToken(user, action, issued)), []byte(token))
+}
+
+// adminFormToken returns a token for a form on an admin page that posts to
+// action
+func adminFormToken(r *http.Request, action string) string {
+	user, _, _ := r.BasicAuth()
+	return adminToken(user, action, time.Now())
+}
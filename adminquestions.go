@@ -0,0 +1,552 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bankEditMux serializes read-modify-write cycles on the question bank
+var bankEditMux sync.Mutex
+
+// errInvalidBank is returned when an edit would leave the bank with lint
+// errors
+var errInvalidBank = errors.New("question bank has errors")
+
+// rejectedEdit explains why an edit does not fit the current bank
+type rejectedEdit string
+
+func (e rejectedEdit) Error() string { return string(e) }
+
+// errUnchanged is returned by an edit to leave the bank as it is without
+// writing it
+var errUnchanged = errors.New("question bank unchanged")
+
+// errEditConflict is returned when a question changed after the admin opened
+// it for editing
+var errEditConflict = errors.New("question was changed by someone else")
+
+// activeQuestions returns the questions that have not been retired
+func activeQuestions(questions []Question) []Question {
+	var active []Question
+	for _, q := range questions {
+		if !q.Retired {
+			active = append(active, q)
+		}
+	}
+	return active
+}
+
+// editableBank returns the path of the bank file the admin pages write to and
+// its questions. Only a single bank file can be edited; without one, the
+// first edit to the embedded default bank creates questions.json.
+func editableBank() (string, []Question, error) {
+	switch source := defaultQuestionSource().(type) {
+	case FileSource:
+		questions, err := readQuestionBank(source.Path)
+		return source.Path, questions, err
+	case EmbeddedSource:
+		questions, err := source.Load()
+		return questionBankPath(), questions, err
+	}
+	return "", nil, fmt.Errorf("%s is not a single bank file and cannot be edited here", questionBankPath())
+}
+
+// backupFile copies the file at path to path.bak, if it exists
+func backupFile(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path+".bak", data)
+}
+
+// editQuestionBank applies edit to the question bank and saves the result if
+// it passes the checks that loadQuestions applies, keeping the previous file
+// as a .bak. It returns the issues found in the edited bank, and
+// errInvalidBank without saving if any are errors. An edit returning
+// errUnchanged leaves the file alone.
+func editQuestionBank(edit func([]Question) ([]Question, error)) ([]lintIssue, error) {
+	bankEditMux.Lock()
+	defer bankEditMux.Unlock()
+
+	path, questions, err := editableBank()
+	if err != nil {
+		return nil, err
+	}
+	if questions, err = edit(questions); errors.Is(err, errUnchanged) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	issues := lintQuestions(questions)
+	if countSeverity(issues, severityError) > 0 {
+		return issues, errInvalidBank
+	}
+	if err := backupFile(path); err != nil {
+		return issues, err
+	}
+	return issues, writeQuestionBank(path, questions)
+}
+
+// questionListPage is the template data for the question list
+type questionListPage struct {
+	Query     string
+	Tag       string
+	Status    string
+	Questions []Question
+	Total     int
+	Tags      []string
+	// Editable is false when the bank is a directory or URL
+	Editable bool
+	Saved    int
+	// RetireToken authorizes the retire buttons
+	RetireToken string
+}
+
+// matches reports whether the question contains the search text in its ID,
+// "namespace:id" reference, text, choices, explanation or tags
+func (p questionListPage) matches(q Question) bool {
+	switch {
+	case p.Status == "active" && q.Retired, p.Status == "retired" && !q.Retired:
+		return false
+	}
+	if p.Tag != "" {
+		tagged := false
+		for _, t := range q.Tags {
+			tagged = tagged || t == p.Tag
+		}
+		if !tagged {
+			return false
+		}
+	}
+	query := strings.ToLower(strings.TrimSpace(p.Query))
+	if query == "" || strconv.Itoa(q.ID) == query || strings.ToLower(q.Ref()) == query {
+		return true
+	}
+	fields := append([]string{q.Question, q.Explanation}, q.Choices...)
+	fields = append(fields, q.Tags...)
+	for _, f := range fields {
+		if strings.Contains(strings.ToLower(f), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// adminQuestionsHandler handles GET /admin/questions, listing the bank's
+// questions filtered by the "q" search text, "tag" and "status" (active or
+// retired)
+func adminQuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	questions, err := loadQuestions()
+	if err != nil {
+		log.Printf("Error loading questions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	page := questionListPage{
+		Query:  query.Get("q"),
+		Tag:    query.Get("tag"),
+		Status: query.Get("status"),
+		Total:  len(questions),
+	}
+	page.Saved, _ = strconv.Atoi(query.Get("saved"))
+	_, _, err = editableBank()
+	page.Editable = err == nil
+
+	tags := make(map[string]bool)
+	for _, q := range questions {
+		for _, t := range q.Tags {
+			tags[t] = true
+		}
+		if page.matches(q) {
+			page.Questions = append(page.Questions, q)
+		}
+	}
+	for t := range tags {
+		page.Tags = append(page.Tags, t)
+	}
+	sort.Strings(page.Tags)
+	sort.Slice(page.Questions, func(i, j int) bool { return page.Questions[i].ID < page.Questions[j].ID })
+
+	page.RetireToken = adminFormToken(r, "/admin/questions/retire")
+	renderPage(w, "admin_questions.html", page)
+}
+
+// questionEditPage is the template data for the question editor. The form
+// fields are kept as text so a rejected edit is shown as it was entered.
+type questionEditPage struct {
+	New            bool
+	ID             string
+	Text           string
+	Choices        string
+	AnswerIndex    string
+	Explanation    string
+	Hint           string
+	Tags           string
+	Difficulty     string
+	Discrimination string
+	Template       string
+	// BaseVersion is the version the edit started from, used to detect
+	// concurrent edits
+	BaseVersion int
+	Retired     bool
+	Errors      []string
+	Warnings    []string
+	// Token and PreviewToken authorize saving and previewing the form
+	Token        string
+	PreviewToken string
+}
+
+// sign adds the admin form tokens for saving and previewing
+func (p *questionEditPage) sign(r *http.Request) {
+	p.Token = adminFormToken(r, "/admin/questions/edit")
+	p.PreviewToken = adminFormToken(r, "/admin/questions/preview")
+}
+
+// newQuestionEditPage fills the editor with an existing question
+func newQuestionEditPage(q Question) questionEditPage {
+	page := questionEditPage{
+		ID:          strconv.Itoa(q.ID),
+		Text:        q.Question,
+		Choices:     strings.Join(q.Choices, "\n"),
+		AnswerIndex: strconv.Itoa(q.AnswerIndex),
+		Explanation: q.Explanation,
+		Hint:        q.Hint,
+		Tags:        strings.Join(q.Tags, ", "),
+		BaseVersion: q.version(),
+		Retired:     q.Retired,
+	}
+	if q.Difficulty != 0 {
+		page.Difficulty = strconv.FormatFloat(q.Difficulty, 'f', -1, 64)
+	}
+	if q.Discrimination != 0 {
+		page.Discrimination = strconv.FormatFloat(q.Discrimination, 'f', -1, 64)
+	}
+	if q.Template != nil {
+		data, _ := json.MarshalIndent(q.Template, "", "  ")
+		page.Template = string(data)
+		page.AnswerIndex = ""
+	}
+	return page
+}
+
+// editPageFromForm keeps the submitted form fields for showing the editor
+// again
+func editPageFromForm(r *http.Request) questionEditPage {
+	page := questionEditPage{
+		New:            r.FormValue("new") != "",
+		ID:             r.FormValue("id"),
+		Text:           r.FormValue("question"),
+		Choices:        r.FormValue("choices"),
+		AnswerIndex:    r.FormValue("answer_index"),
+		Explanation:    r.FormValue("explanation"),
+		Hint:           r.FormValue("hint"),
+		Tags:           r.FormValue("tags"),
+		Difficulty:     r.FormValue("difficulty"),
+		Discrimination: r.FormValue("discrimination"),
+		Template:       r.FormValue("template"),
+	}
+	page.BaseVersion, _ = strconv.Atoi(r.FormValue("base_version"))
+	return page
+}
+
+// question converts the editor's fields to a question, with one message per
+// field that cannot be parsed. Choices are one per line, blank lines
+// ignored; tags are comma separated. An empty ID is left as 0 for the caller
+// to assign.
+func (p questionEditPage) question() (Question, []string) {
+	var errs []string
+	q := Question{
+		Question:    strings.TrimSpace(p.Text),
+		Explanation: strings.TrimSpace(p.Explanation),
+		Hint:        strings.TrimSpace(p.Hint),
+		Retired:     p.Retired,
+	}
+	if id := strings.TrimSpace(p.ID); id != "" {
+		var err error
+		if q.ID, err = strconv.Atoi(id); err != nil || q.ID <= 0 {
+			errs = append(errs, fmt.Sprintf("ID %q must be a positive whole number", id))
+		}
+	}
+	for _, line := range strings.Split(p.Choices, "\n") {
+		if c := strings.TrimSpace(line); c != "" {
+			q.Choices = append(q.Choices, c)
+		}
+	}
+	for _, t := range strings.Split(p.Tags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			q.Tags = append(q.Tags, t)
+		}
+	}
+
+	if tmpl := strings.TrimSpace(p.Template); tmpl != "" {
+		dec := json.NewDecoder(strings.NewReader(tmpl))
+		dec.DisallowUnknownFields()
+		q.Template = &QuestionTemplate{}
+		if err := dec.Decode(q.Template); err != nil {
+			errs = append(errs, fmt.Sprintf("template: %v", err))
+		}
+	} else if idx := strings.TrimSpace(p.AnswerIndex); idx != "" {
+		var err error
+		if q.AnswerIndex, err = strconv.Atoi(idx); err != nil {
+			errs = append(errs, fmt.Sprintf("answer index %q must be a whole number", idx))
+		}
+	}
+
+	parseFloat := func(name, value string, dst *float64) {
+		if value = strings.TrimSpace(value); value == "" {
+			return
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s %q must be a number", name, value))
+			return
+		}
+		*dst = v
+	}
+	parseFloat("difficulty", p.Difficulty, &q.Difficulty)
+	parseFloat("discrimination", p.Discrimination, &q.Discrimination)
+	return q, errs
+}
+
+// adminQuestionEditHandler handles /admin/questions/edit. GET shows the
+// editor for the bank question given by "question", or an empty one; POST
+// validates the submitted question and saves it into the bank.
+func adminQuestionEditHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		page := questionEditPage{New: true}
+		if r.URL.Query().Get("question") != "" {
+			q, ok := questionForm(w, r)
+			if !ok {
+				return
+			}
+			page = newQuestionEditPage(q)
+		}
+		page.sign(r)
+		renderPage(w, "admin_question_edit.html", page)
+	case http.MethodPost:
+		saveQuestionEdit(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// saveQuestionEdit saves a submitted question, showing the editor again with
+// the problems if it cannot be saved
+func saveQuestionEdit(w http.ResponseWriter, r *http.Request) {
+	page := editPageFromForm(r)
+	edited, errs := page.question()
+	if len(errs) > 0 {
+		page.Errors = errs
+		page.sign(r)
+		renderPageStatus(w, http.StatusUnprocessableEntity, "admin_question_edit.html", page)
+		return
+	}
+
+	var saved Question
+	issues, err := editQuestionBank(func(questions []Question) ([]Question, error) {
+		if edited.ID == 0 {
+			for _, q := range questions {
+				if q.ID > edited.ID {
+					edited.ID = q.ID
+				}
+			}
+			edited.ID++
+		}
+		for i, q := range questions {
+			if q.ID != edited.ID {
+				continue
+			}
+			if page.New {
+				return nil, rejectedEdit(fmt.Sprintf("ID %d is already used by another question", edited.ID))
+			}
+			if q.version() != page.BaseVersion {
+				return nil, errEditConflict
+			}
+			edited.Retired, edited.Author, edited.Submission = q.Retired, q.Author, q.Submission
+			saved = bumpVersion(q, edited)
+			questions[i] = saved
+			return questions, nil
+		}
+		if !page.New {
+			return nil, rejectedEdit(fmt.Sprintf("question %d no longer exists", edited.ID))
+		}
+		saved = edited
+		return append(questions, saved), nil
+	})
+
+	admin, _, _ := r.BasicAuth()
+	var rejected rejectedEdit
+	switch {
+	case err == nil:
+		log.Printf("Question %d saved by %s at version %d", saved.ID, admin, saved.version())
+		http.Redirect(w, r, "/admin/questions?saved="+strconv.Itoa(saved.ID), http.StatusSeeOther)
+		return
+	case errors.Is(err, errInvalidBank):
+		for _, issue := range issues {
+			if issue.Severity == severityError {
+				page.Errors = append(page.Errors, issue.String())
+			}
+		}
+	case errors.Is(err, errEditConflict):
+		page.Errors = []string{"This question was changed since you opened it. Reload it to see the latest version before editing."}
+	case errors.As(err, &rejected):
+		page.Errors = []string{rejected.Error()}
+	default:
+		log.Printf("Error saving question: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	for _, issue := range issues {
+		if issue.Severity == severityWarning && issue.ID == edited.ID {
+			page.Warnings = append(page.Warnings, issue.String())
+		}
+	}
+	page.sign(r)
+	renderPageStatus(w, http.StatusUnprocessableEntity, "admin_question_edit.html", page)
+}
+
+// adminQuestionRetireHandler handles POST /admin/questions/retire, retiring
+// the question with the given "id", or bringing it back with retired=false
+func adminQuestionRetireHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+	retired := true
+	if v := r.FormValue("retired"); v != "" {
+		if retired, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "Invalid retired value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	found := false
+	_, err = editQuestionBank(func(questions []Question) ([]Question, error) {
+		for i := range questions {
+			if questions[i].ID == id {
+				questions[i].Retired = retired
+				found = true
+			}
+		}
+		if !found {
+			return nil, errUnchanged
+		}
+		return questions, nil
+	})
+	switch {
+	case err != nil:
+		log.Printf("Error retiring question %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	case !found:
+		http.Error(w, "Question not found", http.StatusNotFound)
+		return
+	}
+
+	admin, _, _ := r.BasicAuth()
+	log.Printf("Question %d retired=%t by %s", id, retired, admin)
+	http.Redirect(w, r, "/admin/questions?saved="+strconv.Itoa(id), http.StatusSeeOther)
+}
+
+// adminQuestionPreviewHandler handles /admin/questions/preview, rendering a
+// question the way players see it: the bank question given by "question" on
+// GET, or the editor's unsaved fields on POST. Templates show a fresh variant
+// each time.
+func adminQuestionPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	var q Question
+	switch r.Method {
+	case http.MethodGet:
+		var ok bool
+		if q, ok = questionForm(w, r); !ok {
+			return
+		}
+	case http.MethodPost:
+		var errs []string
+		if q, errs = editPageFromForm(r).question(); len(errs) > 0 {
+			http.Error(w, strings.Join(errs, "\n"), http.StatusUnprocessableEntity)
+			return
+		}
+		if issues := lintQuestion(q); lintError(issues) != nil {
+			http.Error(w, lintError(issues).Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	def := defaultQuizDefinition()
+	session := &QuizSession{
+		ID:        "preview",
+		QuizTitle: "Preview",
+		Mode:      def.Mode,
+		StartTime: time.Now(),
+		Seed:      newSessionSeed(),
+		Scoring:   def.Scoring,
+
+		LifelineLimits: def.Lifelines,
+	}
+	var err error
+	if session.Questions, err = session.instantiateAll([]Question{q}); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	page := newQuestionPage(session)
+	page.Preview = true
+	renderQuestion(w, page)
+}
+
+// renderPage renders a page template
+func renderPage(w http.ResponseWriter, name string, data interface{}) {
+	renderPageStatus(w, http.StatusOK, name, data)
+}
+
+// renderPageStatus renders a page template with a status code.
+// The page is rendered to a buffer first so a template error still gets a
+// clean 500.
+func renderPageStatus(w http.ResponseWriter, status int, name string, data interface{}) {
+	tmpl, err := template.ParseFiles(name)
+	if err != nil {
+		log.Printf("Error parsing template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
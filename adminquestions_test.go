@@ -0,0 +1,255 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTempBank points QUESTIONS_PATH at a bank file holding questions and
+// writes stub admin templates for one test
+func useTempBank(t *testing.T, questions []Question) string {
+	useTempVersionStores(t)
+	bank := filepath.Join(t.TempDir(), "questions.json")
+	if err := writeQuestionBank(bank, questions); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("QUESTIONS_PATH", bank)
+	t.Setenv("ADMIN_PASSWORD", "secret")
+
+	templates := map[string]string{
+		"admin_questions.html":     `{{range .Questions}}{{.ID}}:{{.Question}}{{if .Retired}} (retired){{end}};{{end}}`,
+		"admin_question_edit.html": `{{.ID}}|{{.Text}}|{{range .Errors}}{{.}};{{end}}`,
+		"quiz.html":                `{{if .Preview}}preview {{end}}{{.Question.Question}} {{range .Choices}}[{{.Text}}]{{end}}`,
+	}
+	for name, body := range templates {
+		name := name
+		if err := os.WriteFile(name, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.Remove(name) })
+	}
+	return bank
+}
+
+// adminPost submits a form to an admin handler through requireAdmin, with
+// the form token an admin page would have given it
+func adminPost(handler http.HandlerFunc, path string, form url.Values) *httptest.ResponseRecorder {
+	signed := url.Values{adminTokenField: {adminToken("admin", path, time.Now())}}
+	for k, v := range form {
+		signed[k] = v
+	}
+	return adminPostRaw(handler, path, signed)
+}
+
+// adminPostRaw submits a form as is
+func adminPostRaw(handler http.HandlerFunc, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("admin", "secret")
+	rr := httptest.NewRecorder()
+	requireAdmin(handler)(rr, req)
+	return rr
+}
+
+var adminTestBank = []Question{
+	{ID: 1, Question: "Capital of France?", Choices: []string{"London", "Paris"}, AnswerIndex: 0, Explanation: "Paris", Tags: []string{"geo"}},
+	{ID: 2, Question: "Red planet?", Choices: []string{"Mars", "Venus"}, AnswerIndex: 0, Explanation: "Mars"},
+}
+
+func TestQuestionEditPageRoundTrip(t *testing.T) {
+	lo, hi := 1.0, 9.0
+	questions := []Question{
+		{ID: 3, Question: "Q?", Choices: []string{"A", "B c"}, AnswerIndex: 1, Explanation: "E", Hint: "H", Tags: []string{"x", "y z"}, Difficulty: -0.5, Discrimination: 1.2},
+		{ID: 4, Question: "What is {a} + 1?", Template: &QuestionTemplate{
+			Variables:   map[string]TemplateVariable{"a": {Min: &lo, Max: &hi}},
+			Answer:      "a + 1",
+			Distractors: []string{"a"},
+		}},
+	}
+	for _, q := range questions {
+		got, errs := newQuestionEditPage(q).question()
+		if len(errs) > 0 || !reflect.DeepEqual(got, q) {
+			t.Errorf("round trip of %+v gave %+v, %v", q, got, errs)
+		}
+	}
+
+	page := questionEditPage{ID: "x", AnswerIndex: "first", Difficulty: "hard", Template: "{\"answer\": 1}"}
+	if _, errs := page.question(); len(errs) != 3 {
+		t.Errorf("got errors %q, want 3", errs)
+	}
+}
+
+func TestAdminQuestionEdit(t *testing.T) {
+	bank := useTempBank(t, adminTestBank)
+
+	edit := func(fields url.Values) *httptest.ResponseRecorder {
+		return adminPost(adminQuestionEditHandler, "/admin/questions/edit", fields)
+	}
+	fix := url.Values{
+		"id":           {"1"},
+		"question":     {"Capital of France?"},
+		"choices":      {"London\nParis\n"},
+		"answer_index": {"1"},
+		"explanation":  {"Paris"},
+		"tags":         {"geo"},
+		"base_version": {"1"},
+	}
+
+	if rr := edit(fix); rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin/questions?saved=1" {
+		t.Fatalf("save: got %d %q", rr.Code, rr.Body.String())
+	}
+	questions, err := readQuestionBank(bank)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if questions[0].AnswerIndex != 1 || questions[0].Version != 2 {
+		t.Errorf("saved question = %+v, want answer 1 at version 2", questions[0])
+	}
+	if backup, err := readQuestionBank(bank + ".bak"); err != nil || backup[0].AnswerIndex != 0 {
+		t.Errorf("backup = %+v, %v; want the previous bank", backup, err)
+	}
+
+	// Edits that fail the load checks, or started from an older version,
+	// are shown again without saving
+	invalid := url.Values{"id": {"1"}, "question": {"Capital?"}, "choices": {"Paris"}, "base_version": {"2"}}
+	if rr := edit(invalid); rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "too-few-choices") || !strings.HasPrefix(rr.Body.String(), "1|Capital?|") {
+		t.Errorf("invalid edit: got %d %q", rr.Code, rr.Body.String())
+	}
+	if rr := edit(fix); rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "changed since you opened it") {
+		t.Errorf("stale edit: got %d %q", rr.Code, rr.Body.String())
+	}
+	if questions, _ := readQuestionBank(bank); questions[0].Question != "Capital of France?" || questions[0].Version != 2 {
+		t.Errorf("rejected edits changed the bank: %+v", questions[0])
+	}
+
+	// New questions get the next free ID unless one is given
+	added := url.Values{"new": {"1"}, "question": {"Largest ocean?"}, "choices": {"Pacific\nAtlantic"}, "answer_index": {"0"}, "explanation": {"Pacific"}}
+	if rr := edit(added); rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin/questions?saved=3" {
+		t.Errorf("add: got %d %q", rr.Code, rr.Body.String())
+	}
+	added.Set("id", "2")
+	if rr := edit(added); rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "already used") {
+		t.Errorf("add with a used ID: got %d %q", rr.Code, rr.Body.String())
+	}
+	if questions, _ := readQuestionBank(bank); len(questions) != 3 {
+		t.Errorf("bank has %d questions, want 3", len(questions))
+	}
+}
+
+func TestAdminQuestionRetireAndList(t *testing.T) {
+	bank := useTempBank(t, adminTestBank)
+
+	if rr := adminPost(adminQuestionRetireHandler, "/admin/questions/retire", url.Values{"id": {"2"}}); rr.Code != http.StatusSeeOther {
+		t.Fatalf("retire: got %d %q", rr.Code, rr.Body.String())
+	}
+	// A mistyped ID must not replace the backup of the last real change
+	backup, _ := os.ReadFile(bank + ".bak")
+	if rr := adminPost(adminQuestionRetireHandler, "/admin/questions/retire", url.Values{"id": {"9"}}); rr.Code != http.StatusNotFound {
+		t.Errorf("retire unknown: got %d", rr.Code)
+	}
+	if after, _ := os.ReadFile(bank + ".bak"); len(backup) == 0 || string(after) != string(backup) {
+		t.Error("retiring an unknown question rewrote the backup")
+	}
+	questions, err := readQuestionBank(bank)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active := activeQuestions(questions); len(active) != 1 || active[0].ID != 1 {
+		t.Errorf("active questions = %+v", active)
+	}
+
+	list := func(query string) string {
+		rr := httptest.NewRecorder()
+		adminQuestionsHandler(rr, httptest.NewRequest(http.MethodGet, "/admin/questions?"+query, nil))
+		return rr.Body.String()
+	}
+	tests := map[string]string{
+		"":                 "1:Capital of France?;2:Red planet? (retired);",
+		"q=PARIS":          "1:Capital of France?;",
+		"q=2":              "2:Red planet? (retired);",
+		"tag=geo":          "1:Capital of France?;",
+		"status=active":    "1:Capital of France?;",
+		"status=retired":   "2:Red planet? (retired);",
+		"q=nothing+at+all": "",
+	}
+	for query, want := range tests {
+		if got := list(query); got != want {
+			t.Errorf("list %q = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestAdminQuestionPreview(t *testing.T) {
+	useTempBank(t, adminTestBank)
+
+	rr := httptest.NewRecorder()
+	adminQuestionPreviewHandler(rr, httptest.NewRequest(http.MethodGet, "/admin/questions/preview?question=2", nil))
+	if got := rr.Body.String(); got != "preview Red planet? [Mars][Venus]" {
+		t.Errorf("preview = %q", got)
+	}
+
+	draft := url.Values{"question": {"Draft?"}, "choices": {"Yes\nNo"}, "answer_index": {"0"}}
+	if rr := adminPost(adminQuestionPreviewHandler, "/admin/questions/preview", draft); rr.Body.String() != "preview Draft? [Yes][No]" {
+		t.Errorf("draft preview = %d %q", rr.Code, rr.Body.String())
+	}
+	draft.Set("choices", "Yes")
+	if rr := adminPost(adminQuestionPreviewHandler, "/admin/questions/preview", draft); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid draft preview: got %d", rr.Code)
+	}
+}
+
+func TestAdminEditNeedsBankFile(t *testing.T) {
+	t.Setenv("QUESTIONS_PATH", t.TempDir())
+	if _, err := editQuestionBank(func(q []Question) ([]Question, error) { return q, nil }); err == nil || !strings.Contains(err.Error(), "cannot be edited") {
+		t.Errorf("editing a directory bank: got %v", err)
+	}
+}
+
+func TestAdminPostNeedsFormToken(t *testing.T) {
+	bank := useTempBank(t, adminTestBank)
+	const path = "/admin/questions/retire"
+	now := time.Now()
+
+	tokens := map[string]string{
+		"missing":     "",
+		"garbled":     "12345",
+		"other form":  adminToken("admin", "/admin/regrade", now),
+		"other admin": adminToken("root", path, now),
+		"expired":     adminToken("admin", path, now.Add(-adminTokenLifetime-time.Minute)),
+		"future":      adminToken("admin", path, now.Add(time.Hour)),
+		"tampered":    tamper(adminToken("admin", path, now)),
+	}
+	for name, token := range tokens {
+		form := url.Values{"id": {"2"}, adminTokenField: {token}}
+		if rr := adminPostRaw(adminQuestionRetireHandler, path, form); rr.Code != http.StatusForbidden {
+			t.Errorf("%s token: got %d, want 403", name, rr.Code)
+		}
+	}
+	if questions, _ := readQuestionBank(bank); questions[1].Retired {
+		t.Fatal("a POST without a valid token retired a question")
+	}
+
+	// A token handed out by an admin page lets its form through
+	req := httptest.NewRequest(http.MethodGet, "/admin/questions", nil)
+	req.SetBasicAuth("admin", "secret")
+	form := url.Values{"id": {"2"}, adminTokenField: {adminFormToken(req, path)}}
+	if rr := adminPostRaw(adminQuestionRetireHandler, path, form); rr.Code != http.StatusSeeOther {
+		t.Errorf("valid token: got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+// tamper changes the last character of a token
+func tamper(token string) string {
+	last := "A"
+	if strings.HasSuffix(token, last) {
+		last = "B"
+	}
+	return token[:len(token)-1] + last
+}
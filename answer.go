@@ -0,0 +1,221 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// answerHandler handles the POST /answer endpoint. It grades the submitted
+// choice for the session's current question, then renders the next question
+// or redirects to the results page once the quiz is complete. In practice
+// mode the rendered page carries feedback on the answer just given.
+func answerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	sessionID := r.FormValue("sessionID")
+	questionIndex, err := strconv.Atoi(r.FormValue("questionIndex"))
+	if err != nil {
+		http.Error(w, "Invalid question index", http.StatusBadRequest)
+		return
+	}
+
+	// Reject tampered form state before touching the session
+	if !verifyState(sessionID, questionIndex, r.FormValue("hmacSignature")) {
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	}
+
+	choice, err := strconv.Atoi(r.FormValue("choice"))
+	if err != nil {
+		http.Error(w, "Invalid choice", http.StatusBadRequest)
+		return
+	}
+
+	// Confidence is optional unless the quiz uses confidence marking, which
+	// is checked once the session is known
+	confidence, hasConfidence := parseConfidence(r.FormValue("confidence"))
+	if r.FormValue("confidence") != "" && !hasConfidence {
+		http.Error(w, "Invalid confidence", http.StatusBadRequest)
+		return
+	}
+
+	sessionMux.Lock()
+	session, ok := sessions[sessionID]
+	if !ok {
+		sessionMux.Unlock()
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	// An answer arriving after a timed quiz's deadline ends the quiz instead
+	session.checkDeadline(time.Now())
+	if session.Finished() {
+		attempt := session.completedAttempt()
+		sessionMux.Unlock()
+		recordAttempt(session, attempt)
+		http.Redirect(w, r, resultsURL(sessionID), http.StatusSeeOther)
+		return
+	}
+
+	if questionIndex != session.Current {
+		sessionMux.Unlock()
+		http.Error(w, "Question already answered", http.StatusConflict)
+		return
+	}
+
+	if session.awaitingSection() {
+		sessionMux.Unlock()
+		http.Error(w, "Section not started", http.StatusConflict)
+		return
+	}
+
+	if session.paused(time.Now()) {
+		sessionMux.Unlock()
+		http.Error(w, "Quiz is paused", http.StatusConflict)
+		return
+	}
+
+	if session.Scoring.ConfidenceMarking && !hasConfidence {
+		sessionMux.Unlock()
+		http.Error(w, "Confidence is required", http.StatusBadRequest)
+		return
+	}
+
+	question := session.Questions[session.Current]
+	if choice < 0 || choice >= len(question.Choices) {
+		sessionMux.Unlock()
+		http.Error(w, "Invalid choice", http.StatusBadRequest)
+		return
+	}
+	for _, removed := range session.removedChoices(session.Current) {
+		if choice == removed {
+			sessionMux.Unlock()
+			http.Error(w, "Choice was removed by fifty-fifty", http.StatusBadRequest)
+			return
+		}
+	}
+
+	correct := choice == question.AnswerIndex
+
+	// A practice-mode retry only bumps the attempt count on the existing
+	// answer, so the score always reflects the first attempt
+	var event *AnswerEvent
+	study := session.Study
+	if len(session.Answers) > session.Current {
+		session.Answers[session.Current].Attempts++
+	} else {
+		lifelines := session.lifelinesFor(session.Current)
+		points := session.Scoring.points(correct, confidence, lifelines)
+		session.Answers = append(session.Answers, Answer{
+			QuestionID: question.ID,
+			Choice:     choice,
+			Correct:    correct,
+			Confidence: confidence,
+			Points:     points,
+			Lifelines:  lifelines,
+			Attempts:   1,
+
+			QuestionVersion: question.version(),
+		})
+		session.Score += points
+		now := time.Now()
+		event = &AnswerEvent{
+			SessionID:  session.ID,
+			PlayerID:   session.PlayerID,
+			QuestionID: question.ID,
+			Choice:     choice,
+			Correct:    correct,
+			Confidence: confidence,
+			LatencyMs:  now.Sub(session.QuestionStart).Milliseconds(),
+			Time:       now,
+
+			QuizSlug:        session.QuizSlug,
+			Lifelines:       lifelines,
+			QuestionVersion: question.version(),
+			VariantSeed:     question.VariantSeed,
+		}
+	}
+
+	var feedback *AnswerFeedback
+	if session.Mode == ModePractice {
+		feedback = &AnswerFeedback{
+			Question:    question.Question,
+			Correct:     correct,
+			Explanation: question.Explanation,
+		}
+	}
+
+	// Practice mode keeps the player on a question until they get it right
+	if session.Mode == ModePractice && !correct {
+		data := newQuestionPage(session)
+		data.Feedback = feedback
+		sessionMux.Unlock()
+		recordAnswer(event, study)
+		renderQuestion(w, data)
+		return
+	}
+	if session.Branching != nil {
+		session.advanceBranch(choice)
+	}
+	if session.Adaptive != nil {
+		session.advanceAdaptive()
+	}
+	session.Current++
+	session.QuestionStart = time.Now()
+	session.advanceSection()
+
+	if session.Finished() {
+		session.EndTime = time.Now()
+		attempt := session.completedAttempt()
+		sessionMux.Unlock()
+		recordAnswer(event, study)
+		recordAttempt(session, attempt)
+		http.Redirect(w, r, resultsURL(sessionID), http.StatusSeeOther)
+		return
+	}
+
+	// The next section opens on its instructions page
+	if session.awaitingSection() {
+		data := newSectionPage(session)
+		data.Feedback = feedback
+		sessionMux.Unlock()
+		recordAnswer(event, study)
+		renderSection(w, data)
+		return
+	}
+
+	data := newQuestionPage(session)
+	data.Feedback = feedback
+	sessionMux.Unlock()
+
+	recordAnswer(event, study)
+	renderQuestion(w, data)
+}
+
+// recordAnswer appends a scored answer to the history and, for study
+// sessions, updates the player's review schedule. Failures are logged rather
+// than failing the request. Retries pass nil since only first attempts count.
+func recordAnswer(event *AnswerEvent, study bool) {
+	if event == nil {
+		return
+	}
+	if err := appendAnswerEvent(*event); err != nil {
+		log.Printf("Error recording answer history: %v", err)
+	}
+	if study {
+		quality := reviewQuality(event.Correct, event.Confidence)
+		if err := recordReview(event.PlayerID, event.QuestionID, quality, event.Time); err != nil {
+			log.Printf("Error recording study review: %v", err)
+		}
+	}
+}
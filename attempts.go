@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// attemptsPath is the file each player's attempts at limited quizzes are
+// kept in
+var attemptsPath = "attempts.json"
+
+// attemptsMux serializes read-modify-write cycles on the attempts file
+var attemptsMux sync.Mutex
+
+// ScorePolicy decides which attempt's score counts when a quiz allows
+// several attempts
+type ScorePolicy string
+
+const (
+	// KeepLatest counts the most recently finished attempt
+	KeepLatest ScorePolicy = "latest"
+	// KeepBest counts the highest scoring attempt
+	KeepBest ScorePolicy = "best"
+)
+
+// errNoAttemptsLeft is returned when a player has used every attempt at a quiz
+var errNoAttemptsLeft = errors.New("no attempts left")
+
+// AttemptRecord is one attempt by a player at a quiz with an attempt limit
+type AttemptRecord struct {
+	SessionID string    `json:"session_id"`
+	Started   time.Time `json:"started"`
+	Finished  bool      `json:"finished"`
+	Score     int       `json:"score"`
+	MaxScore  int       `json:"max_score"`
+}
+
+// attemptLog maps player IDs to their attempts keyed by quiz slug
+type attemptLog map[string]map[string][]AttemptRecord
+
+// availability reports why a quiz cannot be started at now, or "" if it is
+// open
+func (d QuizDefinition) availability(now time.Time) string {
+	if d.OpensAt != nil && now.Before(*d.OpensAt) {
+		return fmt.Sprintf("This quiz opens at %s.", d.OpensAt.Format(time.RFC1123))
+	}
+	if d.ClosesAt != nil && !now.Before(*d.ClosesAt) {
+		return fmt.Sprintf("This quiz closed at %s.", d.ClosesAt.Format(time.RFC1123))
+	}
+	return ""
+}
+
+// loadAttempts returns the player's attempts at the quiz with the given slug
+func loadAttempts(player, slug string) ([]AttemptRecord, error) {
+	attemptsMux.Lock()
+	defer attemptsMux.Unlock()
+
+	attempts := make(attemptLog)
+	if err := readJSONFile(attemptsPath, &attempts); err != nil {
+		return nil, err
+	}
+	return attempts[player][slug], nil
+}
+
+// startAttempt records a new attempt by the player, returning its number, or
+// errNoAttemptsLeft once maxAttempts have been started
+func startAttempt(player, slug, sessionID string, maxAttempts int, now time.Time) (int, error) {
+	attemptsMux.Lock()
+	defer attemptsMux.Unlock()
+
+	attempts := make(attemptLog)
+	if err := readJSONFile(attemptsPath, &attempts); err != nil {
+		return 0, err
+	}
+	if len(attempts[player][slug]) >= maxAttempts {
+		return 0, errNoAttemptsLeft
+	}
+
+	if attempts[player] == nil {
+		attempts[player] = make(map[string][]AttemptRecord)
+	}
+	attempts[player][slug] = append(attempts[player][slug], AttemptRecord{SessionID: sessionID, Started: now})
+	if err := writeJSONFile(attemptsPath, attempts); err != nil {
+		return 0, err
+	}
+	return len(attempts[player][slug]), nil
+}
+
+// finishAttempt stores the final score of the attempt made in a session
+func finishAttempt(player, slug string, rec AttemptRecord) error {
+	attemptsMux.Lock()
+	defer attemptsMux.Unlock()
+
+	attempts := make(attemptLog)
+	if err := readJSONFile(attemptsPath, &attempts); err != nil {
+		return err
+	}
+	records := attempts[player][slug]
+	for i := range records {
+		if records[i].SessionID == rec.SessionID {
+			records[i].Finished = true
+			records[i].Score = rec.Score
+			records[i].MaxScore = rec.MaxScore
+			return writeJSONFile(attemptsPath, attempts)
+		}
+	}
+	return fmt.Errorf("attempt for session %s not found", rec.SessionID)
+}
+
+// adjustAttemptScores adds each session's score change to its finished
+// attempt, returning how many attempts changed
+func adjustAttemptScores(deltas map[string]int) (int, error) {
+	attemptsMux.Lock()
+	defer attemptsMux.Unlock()
+
+	attempts := make(attemptLog)
+	if err := readJSONFile(attemptsPath, &attempts); err != nil {
+		return 0, err
+	}
+	changed := 0
+	for _, quizzes := range attempts {
+		for _, records := range quizzes {
+			for i := range records {
+				if delta, ok := deltas[records[i].SessionID]; ok && records[i].Finished && delta != 0 {
+					records[i].Score += delta
+					changed++
+				}
+			}
+		}
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	return changed, writeJSONFile(attemptsPath, attempts)
+}
+
+// keptScore returns the finished attempt whose score counts under the policy
+func keptScore(records []AttemptRecord, policy ScorePolicy) (AttemptRecord, bool) {
+	var kept AttemptRecord
+	found := false
+	for _, rec := range records {
+		if !rec.Finished {
+			continue
+		}
+		if !found || policy == KeepLatest || rec.Score > kept.Score {
+			kept = rec
+			found = true
+		}
+	}
+	return kept, found
+}
+
+// completedAttempt returns the attempt record for a session with an attempt
+// limit the first time it is seen finished, and nil otherwise. Callers must
+// hold sessionMux and pass the result to recordAttempt after unlocking.
+func (s *QuizSession) completedAttempt() *AttemptRecord {
+	if s.Attempt == 0 || s.attemptRecorded || !s.Finished() {
+		return nil
+	}
+	s.attemptRecorded = true
+	return &AttemptRecord{SessionID: s.ID, Score: s.Score, MaxScore: s.MaxScore()}
+}
+
+// recordAttempt saves a completed attempt's score. Failures are logged rather
+// than failing the request.
+func recordAttempt(session *QuizSession, rec *AttemptRecord) {
+	if rec == nil {
+		return
+	}
+	// A regrade since completedAttempt changed the session's score but
+	// skipped the unfinished record, so save the score as it is now. Holding
+	// sessionMux keeps a regrade from landing between the two.
+	sessionMux.Lock()
+	defer sessionMux.Unlock()
+	rec.Score = session.Score
+	if err := finishAttempt(session.PlayerID, session.QuizSlug, *rec); err != nil {
+		log.Printf("Error recording attempt: %v", err)
+	}
+}
+
+// unavailablePage is the template data explaining why a quiz cannot be
+// started
+type unavailablePage struct {
+	QuizTitle   string
+	Message     string
+	Attempts    int
+	MaxAttempts int
+	KeepScore   ScorePolicy
+	KeptScore   *AttemptRecord
+}
+
+// renderUnavailable renders the quiz unavailable page with a 403 status
+func renderUnavailable(w http.ResponseWriter, data unavailablePage) {
+	tmpl, err := template.ParseFiles("unavailable.html")
+	if err != nil {
+		log.Printf("Error parsing template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error executing template: %v", err)
+	}
+}
+
+// attemptsUsedPage builds the unavailable page shown once a player has used
+// every attempt at a quiz, including the score that counts
+func attemptsUsedPage(player string, def QuizDefinition) (unavailablePage, error) {
+	records, err := loadAttempts(player, def.Slug)
+	if err != nil {
+		return unavailablePage{}, err
+	}
+	page := unavailablePage{
+		QuizTitle:   def.Title,
+		Message:     fmt.Sprintf("You have used all %d attempts at this quiz.", def.MaxAttempts),
+		Attempts:    len(records),
+		MaxAttempts: def.MaxAttempts,
+		KeepScore:   def.KeepScore,
+	}
+	if kept, ok := keptScore(records, def.KeepScore); ok {
+		page.KeptScore = &kept
+	}
+	return page, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeptScore(t *testing.T) {
+	records := []AttemptRecord{
+		{SessionID: "a", Finished: true, Score: 3},
+		{SessionID: "b", Finished: true, Score: 1},
+		{SessionID: "c", Finished: false},
+	}
+	if kept, _ := keptScore(records, KeepBest); kept.SessionID != "a" {
+		t.Errorf("best kept %q, want a", kept.SessionID)
+	}
+	if kept, _ := keptScore(records, KeepLatest); kept.SessionID != "b" {
+		t.Errorf("latest kept %q, want b", kept.SessionID)
+	}
+	if _, ok := keptScore(records[2:], KeepBest); ok {
+		t.Error("unfinished attempts should not count")
+	}
+}
+
+func TestQuizAvailabilityWindow(t *testing.T) {
+	if err := os.WriteFile("unavailable.html", []byte(`{{.QuizTitle}}: {{.Message}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("unavailable.html")
+	defer os.Remove("quiz.json")
+
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"not yet open", `{"title": "Final", "opens_at": "` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`, "Final: This quiz opens at"},
+		{"closed", `{"title": "Final", "closes_at": "` + time.Now().Add(-time.Hour).Format(time.RFC3339) + `"}`, "Final: This quiz closed at"},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile("quiz.json", []byte(tt.json), 0644); err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		quizHandler(rr, httptest.NewRequest(http.MethodGet, "/quiz", nil))
+		if rr.Code != http.StatusForbidden || !strings.HasPrefix(rr.Body.String(), tt.want) {
+			t.Errorf("%s: got %d %q, want 403 starting %q", tt.name, rr.Code, rr.Body.String(), tt.want)
+		}
+	}
+
+	if err := os.WriteFile("quiz.json", []byte(`{"opens_at": "2030-01-02T00:00:00Z", "closes_at": "2030-01-01T00:00:00Z"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadQuizDefinition(); err == nil {
+		t.Error("Expected error for window closing before it opens, got nil")
+	}
+}
+
+func TestAttemptLimit(t *testing.T) {
+	path := attemptsPath
+	attemptsPath = filepath.Join(t.TempDir(), "attempts.json")
+	defer func() { attemptsPath = path }()
+
+	questionsJSON := `[{"id": 1, "question": "Q1?", "choices": ["A", "B"], "answer_index": 0}]`
+	if err := os.WriteFile("questions.json", []byte(questionsJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("questions.json")
+
+	if err := os.WriteFile("quiz.json", []byte(`{"title": "Final", "max_attempts": 2, "keep_score": "best"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.json")
+
+	templates := map[string]string{
+		"quiz.html":        `{{.SessionID}}`,
+		"results.html":     `attempt {{.Attempt}}/{{.MaxAttempts}} kept {{.KeptScore.Score}}`,
+		"unavailable.html": `{{.Message}} {{.Attempts}}/{{.MaxAttempts}} kept {{.KeptScore.Score}}`,
+	}
+	for name, body := range templates {
+		if err := os.WriteFile(name, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(name)
+	}
+
+	cookie := &http.Cookie{Name: "quiz_player", Value: "attempt-tester"}
+	start := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/quiz", nil)
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		quizHandler(rr, req)
+		return rr
+	}
+
+	// A correct first attempt followed by a wrong second one keeps the best
+	wantResults := []string{"attempt 1/2 kept 1", "attempt 2/2 kept 1"}
+	for i, choice := range []int{0, 1} {
+		rr := start()
+		sessionID := rr.Body.String()
+		if rr.Code != http.StatusOK || sessionID == "" {
+			t.Fatalf("attempt %d should start, got %d %q", i+1, rr.Code, sessionID)
+		}
+		defer func() {
+			sessionMux.Lock()
+			delete(sessions, sessionID)
+			sessionMux.Unlock()
+		}()
+
+		if rr := postAnswer(sessionID, 0, choice); rr.Code != http.StatusSeeOther {
+			t.Fatalf("answer status = %d, want %d", rr.Code, http.StatusSeeOther)
+		}
+		rr = httptest.NewRecorder()
+		resultsHandler(rr, httptest.NewRequest(http.MethodGet, resultsURL(sessionID), nil))
+		if body := rr.Body.String(); body != wantResults[i] {
+			t.Errorf("results for attempt %d = %q, want %q", i+1, body, wantResults[i])
+		}
+	}
+
+	rr := start()
+	want := "You have used all 2 attempts at this quiz. 2/2 kept 1"
+	if rr.Code != http.StatusForbidden || rr.Body.String() != want {
+		t.Errorf("third attempt = %d %q, want 403 %q", rr.Code, rr.Body.String(), want)
+	}
+
+	records, err := loadAttempts("attempt-tester", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || !records[0].Finished || records[0].Score != 1 || records[1].Score != 0 {
+		t.Errorf("attempts = %+v, want two finished attempts scoring 1 and 0", records)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// branchEnd is the routing target that finishes a branching quiz
+const branchEnd = "end"
+
+// BranchNode is a single question in a branching quiz. Next routes a choice
+// index to the name of the following node; choices without an entry go to
+// Default, and an empty or "end" target finishes the quiz. QuestionID is
+// written as an ID or "namespace:id".
+type BranchNode struct {
+	QuestionID QuestionRef    `json:"question_id"`
+	Next       map[int]string `json:"next"`
+	Default    string         `json:"default"`
+}
+
+// BranchGraph describes a quiz as a directed acyclic graph of questions in
+// which the next question depends on the answer given
+type BranchGraph struct {
+	Start string                `json:"start"`
+	Nodes map[string]BranchNode `json:"nodes"`
+}
+
+// target returns the node reached by answering node with choice, or
+// branchEnd when the quiz is over
+func (g *BranchGraph) target(node string, choice int) string {
+	n := g.Nodes[node]
+	next, ok := n.Next[choice]
+	if !ok {
+		next = n.Default
+	}
+	if next == "" {
+		return branchEnd
+	}
+	return next
+}
+
+// targets lists every node name node can route to, excluding branchEnd
+func (g *BranchGraph) targets(node string) []string {
+	n := g.Nodes[node]
+	var out []string
+	for _, next := range n.Next {
+		if next != "" && next != branchEnd {
+			out = append(out, next)
+		}
+	}
+	if n.Default != "" && n.Default != branchEnd {
+		out = append(out, n.Default)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// validate checks that the graph has a valid start, only routes to nodes that
+// exist, has no cycles and has no nodes that cannot be reached from the start
+func (g *BranchGraph) validate() error {
+	if _, ok := g.Nodes[g.Start]; !ok {
+		return fmt.Errorf("branching: start node %q does not exist", g.Start)
+	}
+
+	names := make([]string, 0, len(g.Nodes))
+	for name := range g.Nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == branchEnd {
+			return fmt.Errorf("branching: %q is reserved and cannot name a node", branchEnd)
+		}
+		for _, next := range g.targets(name) {
+			if _, ok := g.Nodes[next]; !ok {
+				return fmt.Errorf("branching: node %q routes to unknown node %q", name, next)
+			}
+		}
+	}
+
+	// Depth-first walk from the start, tracking nodes on the current path to
+	// detect cycles and every node seen to detect unreachable ones
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case onPath:
+			return fmt.Errorf("branching: cycle detected: %v -> %s", path, name)
+		case done:
+			return nil
+		}
+		state[name] = onPath
+		for _, next := range g.targets(name) {
+			if err := visit(next, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		return nil
+	}
+	if err := visit(g.Start, nil); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if state[name] == unvisited {
+			return fmt.Errorf("branching: node %q is unreachable from start node %q", name, g.Start)
+		}
+	}
+	return nil
+}
+
+// resolve checks that every node refers to a question in the bank and that
+// routed choice indices exist, returning the questions keyed by node name
+func (g *BranchGraph) resolve(questions []Question) (map[string]Question, error) {
+	byID := make(map[int]Question, len(questions))
+	for _, q := range questions {
+		byID[q.ID] = q
+	}
+
+	resolved := make(map[string]Question, len(g.Nodes))
+	for name, node := range g.Nodes {
+		q, ok := byID[int(node.QuestionID)]
+		if !ok {
+			return nil, fmt.Errorf("branching: node %q refers to unknown question id %d", name, node.QuestionID)
+		}
+		// Variants shuffle their choices, so routes by choice index would
+		// not follow the player's answer
+		if q.Template != nil {
+			return nil, fmt.Errorf("branching: node %q uses template question %d, which cannot be branched on", name, q.ID)
+		}
+		for choice := range node.Next {
+			if choice < 0 || choice >= len(q.Choices) {
+				return nil, fmt.Errorf("branching: node %q routes choice %d but question %d has %d choices", name, choice, q.ID, len(q.Choices))
+			}
+		}
+		resolved[name] = q
+	}
+	return resolved, nil
+}
+
+// advanceBranch routes a branching session past its current question using
+// the given choice, queuing the next question unless the quiz is over.
+// Callers must hold sessionMux if the session is shared.
+func (s *QuizSession) advanceBranch(choice int) {
+	next := s.Branching.target(s.Path[s.Current], choice)
+	if next != branchEnd {
+		s.Path = append(s.Path, next)
+		s.Questions = append(s.Questions, s.branchQuestions[next])
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBranchGraphValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		graph   BranchGraph
+		wantErr string
+	}{
+		{
+			name: "valid",
+			graph: BranchGraph{Start: "a", Nodes: map[string]BranchNode{
+				"a": {QuestionID: 1, Next: map[int]string{0: "b"}, Default: "c"},
+				"b": {QuestionID: 2, Default: "c"},
+				"c": {QuestionID: 3},
+			}},
+		},
+		{
+			name:    "missing start",
+			graph:   BranchGraph{Start: "x", Nodes: map[string]BranchNode{"a": {QuestionID: 1}}},
+			wantErr: "start node",
+		},
+		{
+			name: "unknown target",
+			graph: BranchGraph{Start: "a", Nodes: map[string]BranchNode{
+				"a": {QuestionID: 1, Default: "b"},
+			}},
+			wantErr: "unknown node",
+		},
+		{
+			name: "cycle",
+			graph: BranchGraph{Start: "a", Nodes: map[string]BranchNode{
+				"a": {QuestionID: 1, Default: "b"},
+				"b": {QuestionID: 2, Next: map[int]string{1: "a"}},
+			}},
+			wantErr: "cycle",
+		},
+		{
+			name: "unreachable",
+			graph: BranchGraph{Start: "a", Nodes: map[string]BranchNode{
+				"a": {QuestionID: 1, Default: branchEnd},
+				"b": {QuestionID: 2},
+			}},
+			wantErr: "unreachable",
+		},
+	}
+
+	for _, tt := range tests {
+		err := tt.graph.validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: validate() returned error: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: validate() error = %v, want it to mention %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBranchGraphResolveRejectsBadReferences(t *testing.T) {
+	questions := []Question{{ID: 1, Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 0}}
+
+	graph := BranchGraph{Start: "a", Nodes: map[string]BranchNode{"a": {QuestionID: 9}}}
+	if _, err := graph.resolve(questions); err == nil {
+		t.Error("Expected error for unknown question id, got nil")
+	}
+
+	graph = BranchGraph{Start: "a", Nodes: map[string]BranchNode{"a": {QuestionID: 1, Next: map[int]string{5: branchEnd}}}}
+	if _, err := graph.resolve(questions); err == nil {
+		t.Error("Expected error for out of range choice, got nil")
+	}
+}
+
+func TestCheckQuizDefinitionsResolvesBranching(t *testing.T) {
+	questions := []Question{
+		{ID: 1, Question: "Q1?", Choices: []string{"A", "B"}},
+		{ID: 2, Question: "Q2?", Choices: []string{"A", "B"}, Retired: true},
+	}
+	defer os.Remove("quiz.json")
+	for body, wantErr := range map[string]string{
+		`{"branching": {"start": "a", "nodes": {"a": {"question_id": 1}}}}`:                                     "",
+		`{"branching": {"start": "a", "nodes": {"a": {"question_id": 9}}}}`:                                     "unknown question id 9",
+		`{"branching": {"start": "a", "nodes": {"a": {"question_id": 2}}}}`:                                     "unknown question id 2",
+		`{"branching": {"start": "a", "nodes": {"a": {"question_id": 1, "next": {"0": "a"}}}}}`:                 "cycle",
+		`{"branching": {"start": "a", "nodes": {"a": {"question_id": 1}, "b": {"question_id": 1}}}}`:            "unreachable",
+		`{"branching": {"start": "a", "nodes": {"a": {"question_id": 1, "next": {"3": "` + branchEnd + `"}}}}}`: "routes choice 3",
+	} {
+		if err := os.WriteFile("quiz.json", []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := checkQuizDefinitions(questions)
+		if wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", body, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%s: error = %v, want it to mention %q", body, err, wantErr)
+		}
+	}
+}
+
+func TestBranchingQuizFollowsAnswers(t *testing.T) {
+	questionsJSON := `[
+		{"id": 1, "question": "Start?", "choices": ["Left", "Right"], "answer_index": 0},
+		{"id": 2, "question": "Left branch?", "choices": ["A", "B"], "answer_index": 0},
+		{"id": 3, "question": "Right branch?", "choices": ["A", "B"], "answer_index": 1}
+	]`
+	if err := os.WriteFile("questions.json", []byte(questionsJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("questions.json")
+
+	quizJSON := `{"title": "Paths", "mode": "exam", "branching": {"start": "start", "nodes": {
+		"start": {"question_id": 1, "next": {"0": "left", "1": "right"}},
+		"left": {"question_id": 2},
+		"right": {"question_id": 3}
+	}}}`
+	if err := os.WriteFile("quiz.json", []byte(quizJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.json")
+
+	if err := os.WriteFile("quiz.html", []byte(`<h1>{{.Question.Question}}</h1>{{.SessionID}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.html")
+
+	rr := httptest.NewRecorder()
+	quizHandler(rr, httptest.NewRequest(http.MethodGet, "/quiz", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Start?") {
+		t.Fatalf("branching quiz should begin at the start node, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	var session *QuizSession
+	sessionMux.RLock()
+	for id, s := range sessions {
+		if strings.Contains(rr.Body.String(), id) {
+			session = s
+		}
+	}
+	sessionMux.RUnlock()
+	if session == nil {
+		t.Fatal("could not find the session started by quizHandler")
+	}
+	defer func() {
+		sessionMux.Lock()
+		delete(sessions, session.ID)
+		sessionMux.Unlock()
+	}()
+
+	rr = postAnswer(session.ID, 0, 1)
+	if !strings.Contains(rr.Body.String(), "Right branch?") {
+		t.Fatalf("choosing Right should route to the right branch, got %q", rr.Body.String())
+	}
+
+	rr = postAnswer(session.ID, 1, 1)
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("answering a leaf node should finish the quiz, got status %d", rr.Code)
+	}
+	if got := strings.Join(session.Path, ","); got != "start,right" {
+		t.Errorf("session path = %q, want %q", got, "start,right")
+	}
+	if session.Score != 1 || session.MaxScore() != 2 {
+		t.Errorf("score = %d/%d, want 1/2", session.Score, session.MaxScore())
+	}
+}
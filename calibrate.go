@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// Calibration models supported by the calibrate command
+const (
+	modelRasch = "rasch"
+	model2PL   = "2pl"
+)
+
+// calibrationIterations is how many rounds of alternating item and ability
+// estimation are run when fitting parameters
+const calibrationIterations = 30
+
+// ItemCalibration is the proposed IRT metadata for one question along with
+// the statistics describing how well the model fits its responses
+type ItemCalibration struct {
+	ID             int     `json:"id"`
+	Difficulty     float64 `json:"difficulty"`
+	Discrimination float64 `json:"discrimination"`
+	StandardError  float64 `json:"standard_error"`
+	Responses      int     `json:"responses"`
+	PValue         float64 `json:"p_value"`
+	Infit          float64 `json:"infit"`
+	Outfit         float64 `json:"outfit"`
+}
+
+// response is one scored answer by a session to a question
+type response struct {
+	session int
+	item    int
+	correct bool
+}
+
+// calibrateItems fits Rasch or 2PL parameters to the answer history by
+// alternating between item estimates (Fisher scoring with weak priors) and
+// ability estimates (EAP). Questions with fewer than minResponses answers are
+// left out of the result.
+func calibrateItems(events []AnswerEvent, model string, minResponses int) []ItemCalibration {
+	counts := make(map[int]int)
+	for _, e := range events {
+		counts[e.QuestionID]++
+	}
+
+	// Index sessions and the questions that have enough data to calibrate
+	itemIndex := make(map[int]int)
+	var itemIDs []int
+	for id, n := range counts {
+		if n >= minResponses {
+			itemIDs = append(itemIDs, id)
+		}
+	}
+	sort.Ints(itemIDs)
+	for i, id := range itemIDs {
+		itemIndex[id] = i
+	}
+
+	sessionIndex := make(map[string]int)
+	var responses []response
+	for _, e := range events {
+		item, ok := itemIndex[e.QuestionID]
+		if !ok {
+			continue
+		}
+		s, ok := sessionIndex[e.SessionID]
+		if !ok {
+			s = len(sessionIndex)
+			sessionIndex[e.SessionID] = s
+		}
+		responses = append(responses, response{session: s, item: item, correct: e.Correct})
+	}
+
+	items := make([]Question, len(itemIDs))
+	for i, id := range itemIDs {
+		items[i] = Question{ID: id, Discrimination: 1}
+	}
+	thetas := make([]float64, len(sessionIndex))
+
+	bySession := make([][]response, len(sessionIndex))
+	byItem := make([][]response, len(itemIDs))
+	for _, r := range responses {
+		bySession[r.session] = append(bySession[r.session], r)
+		byItem[r.item] = append(byItem[r.item], r)
+	}
+
+	for iter := 0; iter < calibrationIterations; iter++ {
+		for i := range items {
+			fitItem(&items[i], byItem[i], thetas, model == model2PL)
+		}
+		for s, rs := range bySession {
+			asked := make([]Question, len(rs))
+			correct := make([]bool, len(rs))
+			for j, r := range rs {
+				asked[j] = items[r.item]
+				correct[j] = r.correct
+			}
+			thetas[s], _ = estimateAbility(asked, correct)
+		}
+	}
+
+	results := make([]ItemCalibration, len(items))
+	for i, q := range items {
+		results[i] = itemFit(q, byItem[i], thetas)
+	}
+	return results
+}
+
+// fitItem runs a few Fisher scoring steps on a question's difficulty (and,
+// for 2PL, log discrimination) given the current ability estimates. Normal
+// priors keep the estimates finite for questions everyone gets right or wrong.
+func fitItem(q *Question, rs []response, thetas []float64, estimateDiscrimination bool) {
+	const (
+		difficultyPriorVar = 4.0
+		logDiscPriorVar    = 0.25
+		maxStep            = 1.0
+	)
+
+	logA := math.Log(q.discrimination())
+	for step := 0; step < 10; step++ {
+		a := math.Exp(logA)
+		scoreB, scoreA := -q.Difficulty/difficultyPriorVar, -logA/logDiscPriorVar
+		infoBB, infoAA, infoBA := 1/difficultyPriorVar, 1/logDiscPriorVar, 0.0
+
+		for _, r := range rs {
+			theta := thetas[r.session]
+			p := 1 / (1 + math.Exp(-a*(theta-q.Difficulty)))
+			w := p * (1 - p)
+			x := 0.0
+			if r.correct {
+				x = 1
+			}
+			gB, gA := -a, a*(theta-q.Difficulty)
+			scoreB += (x - p) * gB
+			scoreA += (x - p) * gA
+			infoBB += w * gB * gB
+			infoAA += w * gA * gA
+			infoBA += w * gB * gA
+		}
+
+		var deltaB, deltaA float64
+		if estimateDiscrimination {
+			det := infoBB*infoAA - infoBA*infoBA
+			if det <= 0 {
+				break
+			}
+			deltaB = (infoAA*scoreB - infoBA*scoreA) / det
+			deltaA = (infoBB*scoreA - infoBA*scoreB) / det
+		} else {
+			deltaB = scoreB / infoBB
+		}
+
+		q.Difficulty += math.Max(-maxStep, math.Min(maxStep, deltaB))
+		logA += math.Max(-maxStep, math.Min(maxStep, deltaA))
+		if math.Abs(deltaB) < 1e-4 && math.Abs(deltaA) < 1e-4 {
+			break
+		}
+	}
+	q.Discrimination = math.Exp(logA)
+}
+
+// itemFit computes the standard error and fit statistics for a calibrated
+// question: infit is the information-weighted mean square residual and outfit
+// the unweighted one, both near 1 when the model fits
+func itemFit(q Question, rs []response, thetas []float64) ItemCalibration {
+	cal := ItemCalibration{
+		ID:             q.ID,
+		Difficulty:     round(q.Difficulty, 3),
+		Discrimination: round(q.Discrimination, 3),
+		Responses:      len(rs),
+	}
+
+	var correct, info, sqResid, variance, outfit float64
+	for _, r := range rs {
+		p := probabilityCorrect(q, thetas[r.session])
+		w := p * (1 - p)
+		x := 0.0
+		if r.correct {
+			x = 1
+			correct++
+		}
+		info += q.discrimination() * q.discrimination() * w
+		sqResid += (x - p) * (x - p)
+		variance += w
+		outfit += (x - p) * (x - p) / w
+	}
+
+	if len(rs) > 0 {
+		cal.PValue = round(correct/float64(len(rs)), 3)
+		cal.Outfit = round(outfit/float64(len(rs)), 3)
+	}
+	if info > 0 {
+		cal.StandardError = round(1/math.Sqrt(info), 3)
+	}
+	if variance > 0 {
+		cal.Infit = round(sqResid/variance, 3)
+	}
+	return cal
+}
+
+// round rounds x to the given number of decimal places
+func round(x float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(x*scale) / scale
+}
+
+// runCalibrate implements the calibrate command, which fits IRT parameters to
+// the recorded answer history and writes them out as proposed question
+// metadata for review before they are copied into questions.json
+func runCalibrate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("calibrate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	historyPath := fs.String("history", answerHistoryPath, "answer history file to read")
+	model := fs.String("model", model2PL, "IRT model to fit: rasch or 2pl")
+	minResponses := fs.Int("min-responses", 20, "minimum answers before a question is calibrated")
+	out := fs.String("out", "calibration.json", "file to write proposed metadata to")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *model != modelRasch && *model != model2PL {
+		fmt.Fprintf(stderr, "calibrate: unknown model %q (want %q or %q)\n", *model, modelRasch, model2PL)
+		return 2
+	}
+
+	events, err := readAnswerHistory(*historyPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "calibrate: %v\n", err)
+		return 1
+	}
+
+	results := calibrateItems(events, *model, *minResponses)
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		fmt.Fprintf(stderr, "calibrate: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(*out, append(data, '\n'), 0644); err != nil {
+		fmt.Fprintf(stderr, "calibrate: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "%-6s %10s %10s %8s %6s %7s %7s %7s\n", "ID", "DIFFICULTY", "DISCRIM", "SE", "N", "P", "INFIT", "OUTFIT")
+	for _, c := range results {
+		fmt.Fprintf(stdout, "%-6d %10.3f %10.3f %8.3f %6d %7.3f %7.3f %7.3f\n",
+			c.ID, c.Difficulty, c.Discrimination, c.StandardError, c.Responses, c.PValue, c.Infit, c.Outfit)
+	}
+	fmt.Fprintf(stdout, "Calibrated %d questions from %d answers; proposed metadata written to %s\n", len(results), len(events), *out)
+	return 0
+}
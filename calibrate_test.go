@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// simulateHistory generates answers from players of known ability to
+// questions of known difficulty under the Rasch model
+func simulateHistory(difficulties map[int]float64, players int) []AnswerEvent {
+	r := rand.New(rand.NewSource(1))
+	var events []AnswerEvent
+	for p := 0; p < players; p++ {
+		theta := r.NormFloat64()
+		for id := 1; id <= len(difficulties); id++ {
+			q := Question{ID: id, Difficulty: difficulties[id]}
+			events = append(events, AnswerEvent{
+				SessionID:  fmt.Sprintf("player-%d", p),
+				QuestionID: id,
+				Correct:    r.Float64() < probabilityCorrect(q, theta),
+			})
+		}
+	}
+	return events
+}
+
+func TestCalibrateItemsRecoversDifficultyOrder(t *testing.T) {
+	difficulties := map[int]float64{1: -1.5, 2: 0, 3: 1.5}
+	results := calibrateItems(simulateHistory(difficulties, 400), modelRasch, 20)
+
+	if len(results) != 3 {
+		t.Fatalf("calibrateItems() returned %d items, want 3", len(results))
+	}
+	if !(results[0].Difficulty < results[1].Difficulty && results[1].Difficulty < results[2].Difficulty) {
+		t.Errorf("calibrated difficulties should keep the true order, got %+v", results)
+	}
+	for _, c := range results {
+		if c.Discrimination != 1 {
+			t.Errorf("Rasch calibration should fix discrimination at 1, got %v for item %d", c.Discrimination, c.ID)
+		}
+		if c.Infit < 0.7 || c.Infit > 1.3 {
+			t.Errorf("item %d infit = %v, want close to 1 for data generated by the model", c.ID, c.Infit)
+		}
+	}
+}
+
+func TestCalibrateItemsSkipsSparseQuestions(t *testing.T) {
+	events := simulateHistory(map[int]float64{1: 0, 2: 0}, 30)
+	events = append(events, AnswerEvent{SessionID: "player-0", QuestionID: 99, Correct: true})
+
+	for _, c := range calibrateItems(events, model2PL, 20) {
+		if c.ID == 99 {
+			t.Errorf("question with one response should not be calibrated: %+v", c)
+		}
+	}
+}
+
+func TestRunCalibrateWritesProposals(t *testing.T) {
+	dir := t.TempDir()
+	historyPath := filepath.Join(dir, "history.jsonl")
+	outPath := filepath.Join(dir, "calibration.json")
+
+	var lines []string
+	for _, e := range simulateHistory(map[int]float64{1: -1, 2: 1}, 50) {
+		line, _ := json.Marshal(e)
+		lines = append(lines, string(line))
+	}
+	if err := os.WriteFile(historyPath, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"calibrate", "-history", historyPath, "-out", outPath}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("calibrate exited %d: %s", code, stderr.String())
+	}
+
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var proposals []ItemCalibration
+	if err := json.Unmarshal(data, &proposals); err != nil {
+		t.Fatalf("calibration output is not valid JSON: %v", err)
+	}
+	if len(proposals) != 2 || proposals[0].Responses != 50 {
+		t.Errorf("unexpected proposals: %+v", proposals)
+	}
+	if !strings.Contains(stdout.String(), "Calibrated 2 questions from 100 answers") {
+		t.Errorf("unexpected summary: %q", stdout.String())
+	}
+}
+
+func TestRunCommandUnknown(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := runCommand([]string{"frobnicate"}, &stdout, &stderr); code != 2 {
+		t.Errorf("unknown command exited %d, want 2", code)
+	}
+	if !strings.Contains(stderr.String(), "calibrate") {
+		t.Errorf("usage should list available commands, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// command is a subcommand run from the command line instead of the server
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) int
+}
+
+// commands lists the available subcommands in the order shown in usage
+var commands = []command{
+	{"calibrate", "fit IRT parameters to the recorded answer history", runCalibrate},
+	{"import", "convert CSV, YAML and GIFT question files into questions.json", runImport},
+	{"lint", "check question banks for errors and style problems", runLint},
+	{"migrate", "rewrite question banks in the current schema version", runMigrate},
+	{"export", "write the question bank as a QTI 2.1 package or Anki TSV", runExport},
+}
+
+// runCommand dispatches args[0] to the matching subcommand and returns the
+// process exit code
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:], stdout, stderr)
+		}
+	}
+
+	fmt.Fprintf(stderr, "unknown command %q\n\nUsage: maestro-demo [command] [flags]\n\nWith no command the quiz server is started. Commands:\n", args[0])
+	for _, c := range commands {
+		fmt.Fprintf(stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+	return 2
+}
@@ -0,0 +1,69 @@
+package main
+
+// Confidence is how sure a player says they are of an answer
+type Confidence string
+
+const (
+	ConfidenceLow    Confidence = "low"
+	ConfidenceMedium Confidence = "medium"
+	ConfidenceHigh   Confidence = "high"
+)
+
+// confidenceLevels lists the confidence levels from least to most certain
+var confidenceLevels = []Confidence{ConfidenceLow, ConfidenceMedium, ConfidenceHigh}
+
+// confidenceMarks holds the points for a correct and an incorrect answer at a
+// given confidence level
+type confidenceMarks struct {
+	Correct   int
+	Incorrect int
+}
+
+// certaintyMarkingTable is the standard certainty-based marking scheme: the
+// more confident the answer, the more it earns when right and the more it
+// costs when wrong, so guessing at high confidence never pays off
+var certaintyMarkingTable = map[Confidence]confidenceMarks{
+	ConfidenceLow:    {Correct: 1, Incorrect: 0},
+	ConfidenceMedium: {Correct: 2, Incorrect: -2},
+	ConfidenceHigh:   {Correct: 3, Incorrect: -6},
+}
+
+// parseConfidence converts a submitted form value into a Confidence
+func parseConfidence(value string) (Confidence, bool) {
+	c := Confidence(value)
+	_, ok := certaintyMarkingTable[c]
+	return c, ok
+}
+
+// CalibrationRow reports how accurate a player was at one confidence level
+type CalibrationRow struct {
+	Confidence Confidence
+	Answered   int
+	Correct    int
+	Accuracy   float64
+}
+
+// calibration groups answers by stated confidence and computes the accuracy
+// at each level. Answers submitted without a confidence are ignored, and
+// levels that were never used are omitted.
+func calibration(answers []Answer) []CalibrationRow {
+	var rows []CalibrationRow
+	for _, level := range confidenceLevels {
+		row := CalibrationRow{Confidence: level}
+		for _, a := range answers {
+			if a.Confidence != level {
+				continue
+			}
+			row.Answered++
+			if a.Correct {
+				row.Correct++
+			}
+		}
+		if row.Answered == 0 {
+			continue
+		}
+		row.Accuracy = float64(row.Correct) * 100 / float64(row.Answered)
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCertaintyBasedMarkingPoints(t *testing.T) {
+	rules := defaultScoringRules()
+	rules.ConfidenceMarking = true
+
+	tests := []struct {
+		correct    bool
+		confidence Confidence
+		want       int
+	}{
+		{true, ConfidenceLow, 1},
+		{false, ConfidenceLow, 0},
+		{true, ConfidenceMedium, 2},
+		{false, ConfidenceMedium, -2},
+		{true, ConfidenceHigh, 3},
+		{false, ConfidenceHigh, -6},
+	}
+	for _, tt := range tests {
+		if got := rules.points(tt.correct, tt.confidence, nil); got != tt.want {
+			t.Errorf("points(%v, %s) = %d, want %d", tt.correct, tt.confidence, got, tt.want)
+		}
+	}
+
+	if got := rules.maxPoints(); got != 3 {
+		t.Errorf("maxPoints() = %d, want 3", got)
+	}
+}
+
+func TestCalibration(t *testing.T) {
+	answers := []Answer{
+		{QuestionID: 1, Correct: true, Confidence: ConfidenceHigh},
+		{QuestionID: 2, Correct: false, Confidence: ConfidenceHigh},
+		{QuestionID: 3, Correct: true, Confidence: ConfidenceLow},
+		{QuestionID: 4, Correct: true},
+	}
+
+	rows := calibration(answers)
+	if len(rows) != 2 {
+		t.Fatalf("calibration() returned %d rows, want 2: %+v", len(rows), rows)
+	}
+	if rows[0].Confidence != ConfidenceLow || rows[0].Accuracy != 100 {
+		t.Errorf("low confidence row = %+v, want 100%% accuracy", rows[0])
+	}
+	if rows[1].Confidence != ConfidenceHigh || rows[1].Answered != 2 || rows[1].Accuracy != 50 {
+		t.Errorf("high confidence row = %+v, want 1 of 2 correct", rows[1])
+	}
+}
+
+func TestAnswerHandlerConfidenceMarking(t *testing.T) {
+	session := newTestSession(t, []Question{
+		{ID: 1, Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+		{ID: 2, Question: "Q2?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+	})
+	session.Scoring.ConfidenceMarking = true
+
+	if rr := postAnswer(session.ID, 0, 1); rr.Code != http.StatusBadRequest {
+		t.Errorf("answer without confidence returned %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+
+	form := url.Values{}
+	form.Set("sessionID", session.ID)
+	form.Set("questionIndex", "0")
+	form.Set("hmacSignature", signState(session.ID, 0))
+	form.Set("choice", "1")
+	form.Set("confidence", string(ConfidenceHigh))
+	req := httptest.NewRequest(http.MethodPost, "/answer", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	answerHandler(httptest.NewRecorder(), req)
+
+	if session.Score != -6 || session.Answers[0].Confidence != ConfidenceHigh {
+		t.Errorf("confidently wrong answer should cost 6 points, got Score=%d answer=%+v", session.Score, session.Answers[0])
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+)
+
+// QuizMode controls how much feedback a player receives while taking a quiz
+type QuizMode string
+
+const (
+	// ModePractice reveals correctness and the explanation after each answer
+	// and lets the player retry a question until they get it right
+	ModePractice QuizMode = "practice"
+	// ModeExam hides all feedback until the results page and never allows a
+	// question to be revisited once answered
+	ModeExam QuizMode = "exam"
+)
+
+// slugPattern restricts quiz slugs to characters that are safe in a URL path
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// QuizDefinition describes how a quiz is run
+type QuizDefinition struct {
+	Slug        string         `json:"slug"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Mode        QuizMode       `json:"mode"`
+	Scoring     ScoringRules   `json:"scoring"`
+	Lifelines   LifelineLimits `json:"lifelines"`
+
+	// Filters narrows the question bank to the questions this quiz draws
+	// from, and Length is how many of them each session gets
+	Filters QuestionFilters `json:"filters"`
+	Length  int             `json:"length"`
+
+	// TimeLimitSeconds ends the session once it has run this long; 0 means
+	// the quiz is untimed
+	TimeLimitSeconds int `json:"time_limit_seconds"`
+
+	// Branching, when set, replaces random selection with a graph of
+	// questions where each answer decides the next question
+	Branching *BranchGraph `json:"branching,omitempty"`
+
+	// Adaptive, when set, picks each question to best measure the player's
+	// ability using the questions' IRT parameters
+	Adaptive *AdaptiveSettings `json:"adaptive,omitempty"`
+
+	// SpacedRepetition, when set, schedules questions per player with SM-2 so
+	// due and previously missed questions come up first
+	SpacedRepetition bool `json:"spaced_repetition"`
+
+	// Sections, when set, split the quiz into ordered parts that each select
+	// their own questions from the filtered bank, replacing Length
+	Sections []Section `json:"sections,omitempty"`
+
+	// OpensAt and ClosesAt bound when new sessions may be started
+	OpensAt  *time.Time `json:"opens_at,omitempty"`
+	ClosesAt *time.Time `json:"closes_at,omitempty"`
+
+	// MaxAttempts limits how many sessions each player may start; 0 means
+	// unlimited. KeepScore decides which attempt's score counts.
+	MaxAttempts int         `json:"max_attempts"`
+	KeepScore   ScorePolicy `json:"keep_score"`
+
+	// MaxPauseSeconds is the total time a player may stop the clock for;
+	// 0 disables pausing
+	MaxPauseSeconds int `json:"max_pause_seconds"`
+}
+
+// QuestionFilters selects a subset of the question bank. Empty filters match
+// every question; a question must satisfy all filters that are set.
+type QuestionFilters struct {
+	// Tags matches questions carrying at least one of the listed tags
+	Tags []string `json:"tags,omitempty"`
+	// IDs matches the listed questions, written as IDs or "namespace:id"
+	IDs           []QuestionRef `json:"ids,omitempty"`
+	MinDifficulty *float64      `json:"min_difficulty,omitempty"`
+	MaxDifficulty *float64      `json:"max_difficulty,omitempty"`
+	// Namespaces matches questions from the listed files of a directory bank
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// apply returns the questions that match the filters
+func (f QuestionFilters) apply(questions []Question) []Question {
+	tags := make(map[string]bool, len(f.Tags))
+	for _, t := range f.Tags {
+		tags[t] = true
+	}
+	ids := make(map[int]bool, len(f.IDs))
+	for _, id := range f.IDs {
+		ids[int(id)] = true
+	}
+	namespaces := make(map[string]bool, len(f.Namespaces))
+	for _, ns := range f.Namespaces {
+		namespaces[ns] = true
+	}
+
+	var matched []Question
+	for _, q := range questions {
+		if len(ids) > 0 && !ids[q.ID] {
+			continue
+		}
+		if len(namespaces) > 0 && !namespaces[q.Namespace] {
+			continue
+		}
+		if len(tags) > 0 {
+			tagged := false
+			for _, t := range q.Tags {
+				tagged = tagged || tags[t]
+			}
+			if !tagged {
+				continue
+			}
+		}
+		if f.MinDifficulty != nil && q.Difficulty < *f.MinDifficulty {
+			continue
+		}
+		if f.MaxDifficulty != nil && q.Difficulty > *f.MaxDifficulty {
+			continue
+		}
+		matched = append(matched, q)
+	}
+	return matched
+}
+
+// TimeLimit returns the quiz's time limit, or 0 if it is untimed
+func (d QuizDefinition) TimeLimit() time.Duration {
+	return time.Duration(d.TimeLimitSeconds) * time.Second
+}
+
+// defaultQuizDefinition is used when no quiz.json file is present
+func defaultQuizDefinition() QuizDefinition {
+	return QuizDefinition{
+		Slug:    "default",
+		Title:   "Quiz",
+		Mode:    ModeExam,
+		Scoring: defaultScoringRules(),
+		Lifelines: LifelineLimits{
+			FiftyFifty: 1,
+			Hint:       1,
+		},
+		Length:    NumQuestions,
+		KeepScore: KeepLatest,
+	}
+}
+
+// loadQuizDefinitions reads the quizzes.json file listing every quiz that can
+// be started. Settings a quiz leaves out take their default values. Without
+// quizzes.json the single quiz from quiz.json (or the default) is offered.
+func loadQuizDefinitions() ([]QuizDefinition, error) {
+	data, err := os.ReadFile("quizzes.json")
+	if errors.Is(err, os.ErrNotExist) {
+		def, err := loadQuizDefinition()
+		if err != nil {
+			return nil, err
+		}
+		return []QuizDefinition{def}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("quizzes.json: no quizzes defined")
+	}
+
+	defs := make([]QuizDefinition, 0, len(raw))
+	slugs := make(map[string]bool, len(raw))
+	for i, r := range raw {
+		def := defaultQuizDefinition()
+		def.Slug = ""
+		if err := json.Unmarshal(r, &def); err != nil {
+			return nil, fmt.Errorf("quizzes.json: quiz %d: %w", i, err)
+		}
+		if err := def.validate(); err != nil {
+			return nil, fmt.Errorf("quizzes.json: quiz %d: %w", i, err)
+		}
+		if slugs[def.Slug] {
+			return nil, fmt.Errorf("quizzes.json: quiz %d: duplicate slug %q", i, def.Slug)
+		}
+		slugs[def.Slug] = true
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+// checkQuizDefinitions loads the quiz definitions and resolves each
+// branching graph against the bank's active questions, so a graph that
+// refers to a missing question or choice is found when the server starts
+// rather than when a player first opens the quiz
+func checkQuizDefinitions(questions []Question) ([]QuizDefinition, error) {
+	defs, err := loadQuizDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	active := activeQuestions(questions)
+	for _, def := range defs {
+		if def.Branching == nil {
+			continue
+		}
+		if _, err := def.Branching.resolve(active); err != nil {
+			return nil, fmt.Errorf("quiz %q: %w", def.Slug, err)
+		}
+	}
+	return defs, nil
+}
+
+// findQuizDefinition returns the definition with the given slug
+func findQuizDefinition(defs []QuizDefinition, slug string) (QuizDefinition, bool) {
+	for _, d := range defs {
+		if d.Slug == slug {
+			return d, true
+		}
+	}
+	return QuizDefinition{}, false
+}
+
+// loadQuizDefinition reads the optional quiz.json file, falling back to the
+// default definition when it does not exist
+func loadQuizDefinition() (QuizDefinition, error) {
+	def := defaultQuizDefinition()
+
+	data, err := os.ReadFile("quiz.json")
+	if errors.Is(err, os.ErrNotExist) {
+		return def, nil
+	}
+	if err != nil {
+		return def, err
+	}
+
+	if err := json.Unmarshal(data, &def); err != nil {
+		return def, err
+	}
+
+	if err := def.validate(); err != nil {
+		return def, err
+	}
+	return def, nil
+}
+
+// validate checks that the definition only uses supported settings
+func (d QuizDefinition) validate() error {
+	if !slugPattern.MatchString(d.Slug) {
+		return fmt.Errorf("quiz %q: slug %q must be lowercase letters, digits and single hyphens", d.Title, d.Slug)
+	}
+
+	if d.Length <= 0 {
+		return fmt.Errorf("quiz %q: length must be positive", d.Title)
+	}
+
+	if d.TimeLimitSeconds < 0 {
+		return fmt.Errorf("quiz %q: time_limit_seconds must not be negative", d.Title)
+	}
+
+	if d.OpensAt != nil && d.ClosesAt != nil && !d.ClosesAt.After(*d.OpensAt) {
+		return fmt.Errorf("quiz %q: closes_at must be after opens_at", d.Title)
+	}
+
+	if d.MaxPauseSeconds < 0 {
+		return fmt.Errorf("quiz %q: max_pause_seconds must not be negative", d.Title)
+	}
+
+	if d.MaxAttempts < 0 {
+		return fmt.Errorf("quiz %q: max_attempts must not be negative", d.Title)
+	}
+
+	switch d.KeepScore {
+	case KeepLatest, KeepBest:
+	default:
+		return fmt.Errorf("quiz %q: unknown keep_score %q (want %q or %q)", d.Title, d.KeepScore, KeepLatest, KeepBest)
+	}
+
+	switch d.Mode {
+	case ModePractice, ModeExam:
+	default:
+		return fmt.Errorf("quiz %q: unknown mode %q (want %q or %q)", d.Title, d.Mode, ModePractice, ModeExam)
+	}
+
+	if d.Lifelines.FiftyFifty < 0 || d.Lifelines.Hint < 0 {
+		return fmt.Errorf("quiz %q: lifeline limits must not be negative", d.Title)
+	}
+
+	if err := d.Scoring.validate(); err != nil {
+		return fmt.Errorf("quiz %q: %w", d.Title, err)
+	}
+
+	if d.Branching != nil {
+		if err := d.Branching.validate(); err != nil {
+			return fmt.Errorf("quiz %q: %w", d.Title, err)
+		}
+	}
+
+	if len(d.Sections) > 0 {
+		if d.Branching != nil || d.Adaptive != nil || d.SpacedRepetition {
+			return fmt.Errorf("quiz %q: sections cannot be combined with branching, adaptive or spaced_repetition", d.Title)
+		}
+		for _, s := range d.Sections {
+			if err := s.validate(); err != nil {
+				return fmt.Errorf("quiz %q: %w", d.Title, err)
+			}
+		}
+	}
+
+	if d.SpacedRepetition && (d.Branching != nil || d.Adaptive != nil) {
+		return fmt.Errorf("quiz %q: spaced_repetition cannot be combined with branching or adaptive", d.Title)
+	}
+
+	if d.Adaptive != nil {
+		if d.Branching != nil {
+			return fmt.Errorf("quiz %q: branching and adaptive cannot be combined", d.Title)
+		}
+		if err := d.Adaptive.validate(); err != nil {
+			return fmt.Errorf("quiz %q: %w", d.Title, err)
+		}
+	}
+	return nil
+}
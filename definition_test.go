@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadQuizDefinitionDefault(t *testing.T) {
+	os.Remove("quiz.json")
+
+	def, err := loadQuizDefinition()
+	if err != nil {
+		t.Fatalf("loadQuizDefinition() returned error: %v", err)
+	}
+	if def.Mode != ModeExam {
+		t.Errorf("default mode = %q, want %q", def.Mode, ModeExam)
+	}
+}
+
+func TestLoadQuizDefinitionMode(t *testing.T) {
+	if err := os.WriteFile("quiz.json", []byte(`{"title":"Drill","mode":"practice"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.json")
+
+	def, err := loadQuizDefinition()
+	if err != nil {
+		t.Fatalf("loadQuizDefinition() returned error: %v", err)
+	}
+	if def.Mode != ModePractice || def.Title != "Drill" {
+		t.Errorf("loadQuizDefinition() = %+v, want practice mode titled Drill", def)
+	}
+
+	if err := os.WriteFile("quiz.json", []byte(`{"title":"Drill","mode":"sudden-death"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadQuizDefinition(); err == nil {
+		t.Error("Expected error for unknown mode, got nil")
+	}
+}
+
+func TestPracticeModeFeedbackAndRetry(t *testing.T) {
+	quizHTML := `<h1>{{.Question.Question}}</h1>{{if .Feedback}}{{if .Feedback.Correct}}Correct{{else}}Incorrect{{end}}: {{.Feedback.Explanation}}{{end}}`
+	if err := os.WriteFile("quiz.html", []byte(quizHTML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.html")
+
+	session := newTestSession(t, []Question{
+		{ID: 1, Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 1, Explanation: "B is right"},
+		{ID: 2, Question: "Q2?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+	})
+	session.Mode = ModePractice
+
+	rr := postAnswer(session.ID, 0, 0)
+	body := rr.Body.String()
+	if !strings.Contains(body, "Incorrect: B is right") || !strings.Contains(body, "Q1?") {
+		t.Errorf("wrong practice answer should show feedback and repeat the question, got %q", body)
+	}
+
+	rr = postAnswer(session.ID, 0, 1)
+	body = rr.Body.String()
+	if !strings.Contains(body, "Correct: B is right") || !strings.Contains(body, "Q2?") {
+		t.Errorf("correct retry should show feedback and move on, got %q", body)
+	}
+
+	if session.Score != 0 {
+		t.Errorf("retried question should not be scored, got Score=%d", session.Score)
+	}
+	if session.Answers[0].Attempts != 2 || session.Answers[0].Choice != 0 {
+		t.Errorf("answer should keep first choice and count attempts, got %+v", session.Answers[0])
+	}
+}
+
+func TestExamModeHidesFeedbackAndForbidsRevisit(t *testing.T) {
+	quizHTML := `<h1>{{.Question.Question}}</h1>{{if .ShowFeedback}}Score: {{.Score}}{{end}}{{if .Feedback}}feedback{{end}}`
+	if err := os.WriteFile("quiz.html", []byte(quizHTML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.html")
+
+	session := newTestSession(t, []Question{
+		{ID: 1, Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 1},
+		{ID: 2, Question: "Q2?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+	})
+
+	rr := postAnswer(session.ID, 0, 0)
+	body := rr.Body.String()
+	if strings.Contains(body, "Score") || strings.Contains(body, "feedback") {
+		t.Errorf("exam mode should not reveal score or feedback, got %q", body)
+	}
+	if !strings.Contains(body, "Q2?") {
+		t.Errorf("exam mode should advance after a wrong answer, got %q", body)
+	}
+
+	rr = postAnswer(session.ID, 0, 1)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("revisiting an answered question returned %d, want %d", rr.Code, http.StatusConflict)
+	}
+}
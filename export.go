@@ -0,0 +1,350 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+)
+
+// Export formats supported by the export command
+const (
+	formatQTI  = "qti"
+	formatAnki = "anki"
+)
+
+// QTI 2.1 namespaces and the resource type for a single item
+const (
+	qtiNamespace      = "http://www.imsglobal.org/xsd/imsqti_v2p1"
+	manifestNamespace = "http://www.imsglobal.org/xsd/imscp_v1p1"
+	qtiItemType       = "imsqti_item_xmlv2p1"
+)
+
+// qtiItem is an IMS QTI 2.1 assessmentItem holding one single-choice
+// question. Response processing scores 1 for the correct choice and always
+// shows the explanation as modal feedback.
+type qtiItem struct {
+	XMLName       xml.Name           `xml:"assessmentItem"`
+	Xmlns         string             `xml:"xmlns,attr"`
+	Identifier    string             `xml:"identifier,attr"`
+	Title         string             `xml:"title,attr"`
+	Adaptive      bool               `xml:"adaptive,attr"`
+	TimeDependent bool               `xml:"timeDependent,attr"`
+	Response      qtiResponseDecl    `xml:"responseDeclaration"`
+	Outcomes      []qtiOutcomeDecl   `xml:"outcomeDeclaration"`
+	Body          qtiItemBody        `xml:"itemBody"`
+	Processing    qtiProcessing      `xml:"responseProcessing"`
+	Feedback      []qtiModalFeedback `xml:"modalFeedback"`
+}
+
+type qtiResponseDecl struct {
+	Identifier  string `xml:"identifier,attr"`
+	Cardinality string `xml:"cardinality,attr"`
+	BaseType    string `xml:"baseType,attr"`
+	Correct     string `xml:"correctResponse>value"`
+}
+
+type qtiOutcomeDecl struct {
+	Identifier  string `xml:"identifier,attr"`
+	Cardinality string `xml:"cardinality,attr"`
+	BaseType    string `xml:"baseType,attr"`
+	Default     string `xml:"defaultValue>value,omitempty"`
+}
+
+type qtiItemBody struct {
+	Interaction qtiChoiceInteraction `xml:"choiceInteraction"`
+}
+
+type qtiChoiceInteraction struct {
+	ResponseIdentifier string            `xml:"responseIdentifier,attr"`
+	Shuffle            bool              `xml:"shuffle,attr"`
+	MaxChoices         int               `xml:"maxChoices,attr"`
+	Prompt             string            `xml:"prompt"`
+	Choices            []qtiSimpleChoice `xml:"simpleChoice"`
+}
+
+type qtiSimpleChoice struct {
+	Identifier string `xml:"identifier,attr"`
+	Text       string `xml:",chardata"`
+}
+
+type qtiProcessing struct {
+	Condition  qtiResponseCondition `xml:"responseCondition"`
+	SetOutcome *qtiSetOutcome       `xml:"setOutcomeValue,omitempty"`
+}
+
+type qtiResponseCondition struct {
+	If   qtiResponseIf `xml:"responseIf"`
+	Else qtiSetOutcome `xml:"responseElse>setOutcomeValue"`
+}
+
+type qtiResponseIf struct {
+	Match      qtiMatch      `xml:"match"`
+	SetOutcome qtiSetOutcome `xml:"setOutcomeValue"`
+}
+
+type qtiMatch struct {
+	Variable qtiRef `xml:"variable"`
+	Correct  qtiRef `xml:"correct"`
+}
+
+type qtiRef struct {
+	Identifier string `xml:"identifier,attr"`
+}
+
+type qtiSetOutcome struct {
+	Identifier string       `xml:"identifier,attr"`
+	Value      qtiBaseValue `xml:"baseValue"`
+}
+
+type qtiBaseValue struct {
+	BaseType string `xml:"baseType,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type qtiModalFeedback struct {
+	OutcomeIdentifier string `xml:"outcomeIdentifier,attr"`
+	ShowHide          string `xml:"showHide,attr"`
+	Identifier        string `xml:"identifier,attr"`
+	Text              string `xml:",chardata"`
+}
+
+// qtiManifest is the IMS content package manifest listing every item
+type qtiManifest struct {
+	XMLName       xml.Name      `xml:"manifest"`
+	Xmlns         string        `xml:"xmlns,attr"`
+	Identifier    string        `xml:"identifier,attr"`
+	Schema        string        `xml:"metadata>schema"`
+	SchemaVersion string        `xml:"metadata>schemaversion"`
+	Organizations string        `xml:"organizations"`
+	Resources     []qtiResource `xml:"resources>resource"`
+}
+
+type qtiResource struct {
+	Identifier string     `xml:"identifier,attr"`
+	Type       string     `xml:"type,attr"`
+	Href       string     `xml:"href,attr"`
+	File       qtiRefFile `xml:"file"`
+}
+
+type qtiRefFile struct {
+	Href string `xml:"href,attr"`
+}
+
+// qtiChoiceID names a choice within an item
+func qtiChoiceID(i int) string {
+	return fmt.Sprintf("choice_%d", i)
+}
+
+// newQTIItem converts a question to a QTI assessment item
+func newQTIItem(q Question) qtiItem {
+	setScore := func(score string) qtiSetOutcome {
+		return qtiSetOutcome{Identifier: "SCORE", Value: qtiBaseValue{BaseType: "float", Value: score}}
+	}
+
+	item := qtiItem{
+		Xmlns:      qtiNamespace,
+		Identifier: fmt.Sprintf("q%d", q.ID),
+		Title:      fmt.Sprintf("Question %d", q.ID),
+		Response: qtiResponseDecl{
+			Identifier:  "RESPONSE",
+			Cardinality: "single",
+			BaseType:    "identifier",
+			Correct:     qtiChoiceID(q.AnswerIndex),
+		},
+		Outcomes: []qtiOutcomeDecl{
+			{Identifier: "SCORE", Cardinality: "single", BaseType: "float", Default: "0"},
+		},
+		Body: qtiItemBody{Interaction: qtiChoiceInteraction{
+			ResponseIdentifier: "RESPONSE",
+			MaxChoices:         1,
+			Prompt:             q.Question,
+		}},
+		Processing: qtiProcessing{Condition: qtiResponseCondition{
+			If: qtiResponseIf{
+				Match:      qtiMatch{Variable: qtiRef{"RESPONSE"}, Correct: qtiRef{"RESPONSE"}},
+				SetOutcome: setScore("1"),
+			},
+			Else: setScore("0"),
+		}},
+	}
+	for i, c := range q.Choices {
+		item.Body.Interaction.Choices = append(item.Body.Interaction.Choices, qtiSimpleChoice{Identifier: qtiChoiceID(i), Text: c})
+	}
+
+	if q.Explanation != "" {
+		item.Outcomes = append(item.Outcomes, qtiOutcomeDecl{Identifier: "FEEDBACK", Cardinality: "single", BaseType: "identifier"})
+		item.Processing.SetOutcome = &qtiSetOutcome{Identifier: "FEEDBACK", Value: qtiBaseValue{BaseType: "identifier", Value: "explanation"}}
+		item.Feedback = []qtiModalFeedback{{OutcomeIdentifier: "FEEDBACK", ShowHide: "show", Identifier: "explanation", Text: q.Explanation}}
+	}
+	return item
+}
+
+// writeQTIPackage writes the questions as a QTI 2.1 content package: a zip
+// with imsmanifest.xml and one item file per question under items/
+func writeQTIPackage(w io.Writer, questions []Question) error {
+	zw := zip.NewWriter(w)
+	manifest := qtiManifest{
+		Xmlns:         manifestNamespace,
+		Identifier:    "MANIFEST-questions",
+		Schema:        "QTIv2.1 Package",
+		SchemaVersion: "1.0.0",
+	}
+
+	for _, q := range questions {
+		item := newQTIItem(q)
+		href := "items/" + item.Identifier + ".xml"
+		if err := writeZipXML(zw, href, item); err != nil {
+			return err
+		}
+		manifest.Resources = append(manifest.Resources, qtiResource{
+			Identifier: item.Identifier,
+			Type:       qtiItemType,
+			Href:       href,
+			File:       qtiRefFile{Href: href},
+		})
+	}
+
+	if err := writeZipXML(zw, "imsmanifest.xml", manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeZipXML adds v to the zip as an indented XML document
+func writeZipXML(zw *zip.Writer, name string, v interface{}) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(f)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, "\n")
+	return err
+}
+
+// ankiField makes text safe for one HTML field of an Anki TSV note
+func ankiField(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, "\t", " ")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// ankiTag makes a tag safe for Anki, which separates tags with spaces
+func ankiTag(tag string) string {
+	return strings.Join(strings.Fields(tag), "_")
+}
+
+// writeAnkiTSV writes one Anki note per question: the question and lettered
+// choices on the front, the correct answer and explanation on the back, and
+// the question's tags. The header lines tell Anki how to read the file.
+func writeAnkiTSV(w io.Writer, questions []Question) error {
+	if _, err := io.WriteString(w, "#separator:tab\n#html:true\n#columns:Front\tBack\tTags\n#tags column:3\n"); err != nil {
+		return err
+	}
+	for _, q := range questions {
+		front := ankiField(q.Question) + "<br><ol type=\"A\">"
+		for _, c := range q.Choices {
+			front += "<li>" + ankiField(c) + "</li>"
+		}
+		front += "</ol>"
+
+		back := fmt.Sprintf("<b>%c. %s</b>", 'A'+q.AnswerIndex, ankiField(q.Choices[q.AnswerIndex]))
+		if q.Explanation != "" {
+			back += "<br>" + ankiField(q.Explanation)
+		}
+
+		tags := make([]string, len(q.Tags))
+		for i, t := range q.Tags {
+			tags[i] = ankiTag(t)
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", front, back, strings.Join(tags, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runExport implements the export command, which writes the question bank as
+// a QTI 2.1 content package for an LMS or as an Anki-importable TSV file
+func runExport(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", formatQTI, "output format: qti or anki")
+	in := fs.String("in", questionBankPath(), "question bank file or directory to export")
+	out := fs.String("out", "", "file to write (default: questions.zip for qti, questions.tsv for anki)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var write func(io.Writer, []Question) error
+	switch *format {
+	case formatQTI:
+		write = writeQTIPackage
+		if *out == "" {
+			*out = "questions.zip"
+		}
+	case formatAnki:
+		write = writeAnkiTSV
+		if *out == "" {
+			*out = "questions.tsv"
+		}
+	default:
+		fmt.Fprintf(stderr, "export: unknown format %q (want %q or %q)\n", *format, formatQTI, formatAnki)
+		return 2
+	}
+
+	questions, err := loadQuestionsFrom(*in)
+	if err != nil {
+		fmt.Fprintf(stderr, "export: %v\n", err)
+		return 1
+	}
+	// Retired questions are no longer served, so they are not exported either
+	active := activeQuestions(questions)
+	if retired := len(questions) - len(active); retired > 0 {
+		fmt.Fprintf(stderr, "export: skipped %d retired questions\n", retired)
+	}
+	questions = active
+
+	// Neither format can generate variants, so template questions are left
+	// out rather than exported with one fixed set of numbers
+	fixed := questions[:0]
+	for _, q := range questions {
+		if q.Template == nil {
+			fixed = append(fixed, q)
+		}
+	}
+	if skipped := len(questions) - len(fixed); skipped > 0 {
+		fmt.Fprintf(stderr, "export: skipped %d template questions\n", skipped)
+	}
+	questions = fixed
+
+	f, err := os.Create(*out)
+	if err != nil {
+		fmt.Fprintf(stderr, "export: %v\n", err)
+		return 1
+	}
+	if err := write(f, questions); err != nil {
+		f.Close()
+		fmt.Fprintf(stderr, "export: %v\n", err)
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintf(stderr, "export: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Exported %d questions to %s\n", len(questions), *out)
+	return 0
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// readQTIPackage reads the questions back out of a QTI package written by
+// writeQTIPackage, following the manifest to each item
+func readQTIPackage(t *testing.T, path string) []Question {
+	t.Helper()
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("opening package: %v", err)
+	}
+	defer zr.Close()
+
+	decode := func(name string, v interface{}) {
+		f, err := zr.Open(name)
+		if err != nil {
+			t.Fatalf("opening %s: %v", name, err)
+		}
+		defer f.Close()
+		if err := xml.NewDecoder(f).Decode(v); err != nil {
+			t.Fatalf("decoding %s: %v", name, err)
+		}
+	}
+
+	var manifest qtiManifest
+	decode("imsmanifest.xml", &manifest)
+	if manifest.Xmlns != manifestNamespace {
+		t.Errorf("manifest namespace = %q", manifest.Xmlns)
+	}
+
+	var questions []Question
+	for _, res := range manifest.Resources {
+		if res.Type != qtiItemType || res.File.Href != res.Href {
+			t.Errorf("resource = %+v", res)
+		}
+		var item qtiItem
+		decode(res.Href, &item)
+		if item.Xmlns != qtiNamespace {
+			t.Errorf("%s namespace = %q", res.Href, item.Xmlns)
+		}
+
+		id, _ := strconv.Atoi(strings.TrimPrefix(item.Identifier, "q"))
+		q := Question{ID: id, Question: item.Body.Interaction.Prompt, AnswerIndex: -1}
+		for i, c := range item.Body.Interaction.Choices {
+			if c.Identifier == item.Response.Correct {
+				q.AnswerIndex = i
+			}
+			q.Choices = append(q.Choices, c.Text)
+		}
+		for _, f := range item.Feedback {
+			q.Explanation = f.Text
+		}
+		questions = append(questions, q)
+	}
+	return questions
+}
+
+func TestExportQTIRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "bank.csv")
+	bankPath := filepath.Join(dir, "questions.json")
+	zipPath := filepath.Join(dir, "questions.zip")
+
+	input := `id,question,choice1,choice2,choice3,answer_index,explanation
+1,Is 3 < 5 & 5 > 3?,Yes,No,,0,"Both say the same thing, in ""different"" words"
+2,What is 2 + 2?,3,4,5,1,
+`
+	if err := os.WriteFile(csvPath, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runCommand([]string{"import", "-out", bankPath, csvPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("import exited %d: %s", code, stderr.String())
+	}
+	if code := runCommand([]string{"export", "-in", bankPath, "-out", zipPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("export exited %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Exported 2 questions to "+zipPath) {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+
+	bank, err := loadQuestionsFrom(bankPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readQTIPackage(t, zipPath); !reflect.DeepEqual(got, bank) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, bank)
+	}
+}
+
+func TestNewQTIItemFeedback(t *testing.T) {
+	item := newQTIItem(Question{ID: 3, Question: "Q?", Choices: []string{"A", "B"}})
+	if item.Processing.SetOutcome != nil || len(item.Feedback) != 0 || len(item.Outcomes) != 1 {
+		t.Errorf("item without explanation declares feedback: %+v", item)
+	}
+
+	item = newQTIItem(Question{ID: 3, Question: "Q?", Choices: []string{"A", "B"}, Explanation: "Because"})
+	if item.Processing.SetOutcome == nil || item.Processing.SetOutcome.Identifier != "FEEDBACK" {
+		t.Errorf("response processing does not set FEEDBACK: %+v", item.Processing)
+	}
+	if len(item.Outcomes) != 2 || item.Outcomes[1].Identifier != "FEEDBACK" {
+		t.Errorf("outcomes = %+v, want SCORE and FEEDBACK", item.Outcomes)
+	}
+}
+
+func TestWriteAnkiTSV(t *testing.T) {
+	questions := []Question{
+		{ID: 1, Question: "Is 3 < 5?\nThink\tcarefully", Choices: []string{"Yes", "No"}, AnswerIndex: 0, Explanation: "3 is less than 5", Tags: []string{"math", "number line"}},
+		{ID: 2, Question: "Capital of France?", Choices: []string{"London", "Paris"}, AnswerIndex: 1},
+	}
+	var buf bytes.Buffer
+	if err := writeAnkiTSV(&buf, questions); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("got %d lines, want 4 header lines and 2 notes:\n%s", len(lines), buf.String())
+	}
+	if lines[0] != "#separator:tab" || lines[1] != "#html:true" {
+		t.Errorf("header = %q", lines[:4])
+	}
+
+	want := [][]string{
+		{
+			`Is 3 &lt; 5?<br>Think carefully<br><ol type="A"><li>Yes</li><li>No</li></ol>`,
+			"<b>A. Yes</b><br>3 is less than 5",
+			"math number_line",
+		},
+		{
+			`Capital of France?<br><ol type="A"><li>London</li><li>Paris</li></ol>`,
+			"<b>B. Paris</b>",
+			"",
+		},
+	}
+	for i, w := range want {
+		if got := strings.Split(lines[4+i], "\t"); !reflect.DeepEqual(got, w) {
+			t.Errorf("note %d:\n got %q\nwant %q", i+1, got, w)
+		}
+	}
+}
+
+func TestRunExportUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := runExport([]string{"-format", "pdf"}, &stdout, &stderr); code != 2 {
+		t.Errorf("unknown format exited %d, want 2", code)
+	}
+
+	dir := t.TempDir()
+	code := runExport([]string{"-in", filepath.Join(dir, "missing.json"), "-out", filepath.Join(dir, "out.tsv"), "-format", "anki"}, &stdout, &stderr)
+	if code != 1 {
+		t.Errorf("missing bank exited %d, want 1", code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out.tsv")); !os.IsNotExist(err) {
+		t.Errorf("output written despite error: %v", err)
+	}
+}
+
+func TestRunExportSkipsRetired(t *testing.T) {
+	dir := t.TempDir()
+	bank := filepath.Join(dir, "questions.json")
+	questions := []Question{
+		{ID: 1, Question: "Kept?", Choices: []string{"A", "B"}, Explanation: "x"},
+		{ID: 2, Question: "Retired?", Choices: []string{"A", "B"}, Explanation: "x", Retired: true},
+	}
+	if err := writeQuestionBank(bank, questions); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	out := filepath.Join(dir, "out.tsv")
+	if code := runExport([]string{"-in", bank, "-out", out, "-format", "anki"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("export exited %d: %s", code, stderr.String())
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "Kept?") || strings.Contains(string(data), "Retired?") {
+		t.Errorf("export = %q, want only the active question", data)
+	}
+	if !strings.Contains(stderr.String(), "skipped 1 retired questions") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+}
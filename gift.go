@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// giftQuestion is the raw text of one GIFT question and the line it starts on
+type giftQuestion struct {
+	Line int
+	Text string
+	Tags []string
+}
+
+// parseGIFT reads multiple-choice and true/false questions from a Moodle GIFT
+// file. Questions are separated by blank lines, // starts a comment line and
+// $CATEGORY: lines tag the questions that follow with the category.
+//
+//	::Capitals:: What is the capital of France? {
+//	  ~London #That is the capital of the UK
+//	  =Paris
+//	  ####Paris has been the capital since 987.
+//	}
+//
+// The #feedback on the correct answer, or the #### general feedback, becomes
+// the explanation. Other GIFT question types are reported as errors.
+func parseGIFT(r io.Reader) ([]importedQuestion, []error) {
+	var blocks []giftQuestion
+	var errs []error
+	var current *giftQuestion
+	var category []string
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(text, "//"):
+			continue
+		case text == "":
+			current = nil
+			continue
+		case strings.HasPrefix(text, "$CATEGORY:") && current == nil:
+			category = nil
+			if c := strings.TrimSpace(strings.TrimPrefix(text, "$CATEGORY:")); c != "" {
+				category = []string{c}
+			}
+			continue
+		}
+
+		if current == nil {
+			blocks = append(blocks, giftQuestion{Line: line, Tags: category})
+			current = &blocks[len(blocks)-1]
+		} else {
+			current.Text += "\n"
+		}
+		current.Text += text
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	var questions []importedQuestion
+	for _, b := range blocks {
+		q, err := parseGIFTQuestion(b.Text)
+		if err != nil {
+			errs = append(errs, lineError{b.Line, err})
+			continue
+		}
+		q.Line = b.Line
+		q.Tags = b.Tags
+		questions = append(questions, q)
+	}
+	return questions, errs
+}
+
+// parseGIFTQuestion converts the text of a single GIFT question
+func parseGIFTQuestion(text string) (importedQuestion, error) {
+	var q importedQuestion
+
+	// Drop the optional ::title::
+	if strings.HasPrefix(text, "::") {
+		end := strings.Index(text[2:], "::")
+		if end < 0 {
+			return q, errors.New("unterminated ::title::")
+		}
+		text = text[end+4:]
+	}
+
+	open := indexUnescaped(text, "{")
+	if open < 0 {
+		return q, errors.New("question has no {answers}")
+	}
+	closing := indexUnescaped(text[open:], "}")
+	if closing < 0 {
+		return q, errors.New("unterminated {answers}")
+	}
+	closing += open
+
+	// Text after the answers makes a fill-in-the-blank question
+	stem := strings.TrimSpace(text[:open])
+	if after := strings.TrimSpace(text[closing+1:]); after != "" {
+		stem += " _____ " + after
+	}
+	q.Question.Question = unescapeGIFT(stem)
+	if q.Question.Question == "" {
+		return q, errors.New("question text is missing")
+	}
+
+	answers, general, _ := cutUnescaped(strings.TrimSpace(text[open+1:closing]), "####")
+	q.Explanation = unescapeGIFT(strings.TrimSpace(general))
+
+	// True/false questions use {T} or {F}, optionally followed by feedback
+	// for a wrong answer and then for a right one
+	answer, feedback, _ := cutUnescaped(answers, "#")
+	switch strings.ToUpper(strings.TrimSpace(answer)) {
+	case "T", "TRUE", "F", "FALSE":
+		q.Choices = []string{"True", "False"}
+		if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(answer)), "F") {
+			q.AnswerIndex = 1
+		}
+		wrong, right, _ := cutUnescaped(feedback, "#")
+		for _, f := range []string{right, wrong} {
+			if q.Explanation == "" {
+				q.Explanation = unescapeGIFT(strings.TrimSpace(f))
+			}
+		}
+		return q, nil
+	}
+
+	if strings.HasPrefix(answers, "#") {
+		return q, errors.New("numerical questions are not supported")
+	}
+
+	correct := 0
+	for _, a := range splitGIFTAnswers(answers) {
+		choice, feedback, _ := cutUnescaped(a[1:], "#")
+		choice = strings.TrimSpace(choice)
+		if strings.HasPrefix(choice, "%") {
+			return q, errors.New("weighted answers are not supported")
+		}
+		if indexUnescaped(choice, "->") >= 0 {
+			return q, errors.New("matching questions are not supported")
+		}
+		if a[0] == '=' {
+			correct++
+			q.AnswerIndex = len(q.Choices)
+			if q.Explanation == "" {
+				q.Explanation = unescapeGIFT(strings.TrimSpace(feedback))
+			}
+		}
+		q.Choices = append(q.Choices, unescapeGIFT(choice))
+	}
+
+	switch {
+	case correct == 0:
+		return q, errors.New("no correct answer marked with =")
+	case correct > 1 && correct == len(q.Choices):
+		return q, errors.New("short answer questions are not supported")
+	case correct > 1:
+		return q, errors.New("more than one correct answer marked with =")
+	case len(q.Choices) < 2:
+		return q, errors.New("short answer questions are not supported")
+	}
+	return q, nil
+}
+
+// splitGIFTAnswers splits an answer block into its answers, each starting
+// with = or ~
+func splitGIFTAnswers(body string) []string {
+	var answers []string
+	start := -1
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			i++
+		case '=', '~':
+			if start >= 0 {
+				answers = append(answers, body[start:i])
+			}
+			start = i
+		}
+	}
+	if start >= 0 {
+		answers = append(answers, body[start:])
+	}
+	return answers
+}
+
+// indexUnescaped returns the index of the first sep not preceded by a
+// backslash, or -1
+func indexUnescaped(s, sep string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], sep) {
+			return i
+		}
+	}
+	return -1
+}
+
+// cutUnescaped is strings.Cut ignoring separators escaped by a backslash
+func cutUnescaped(s, sep string) (before, after string, found bool) {
+	if i := indexUnescaped(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// giftEscapes undoes GIFT's backslash escapes
+var giftEscapes = strings.NewReplacer(
+	`\~`, "~", `\=`, "=", `\#`, "#", `\{`, "{", `\}`, "}", `\:`, ":", `\n`, "\n", `\\`, `\`,
+)
+
+// unescapeGIFT returns GIFT text with its escapes replaced and line breaks
+// folded into spaces
+func unescapeGIFT(s string) string {
+	return giftEscapes.Replace(strings.Join(strings.Fields(s), " "))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGIFT(t *testing.T) {
+	input := `// Exported from Moodle
+$CATEGORY: geography
+
+::Capitals:: What is the capital of France? {
+  ~London #That is the capital of the UK
+  =Paris #Correct
+  ~Berlin
+}
+
+The Seine flows through Paris.{TRUE#It does#Right, it does}
+
+Two plus two equals {~three =four ~five} in base ten.
+
+::Escapes:: What does \{x\} mean in set notation? {=a set containing x ~a function \= x ####Curly braces enclose set members.}
+`
+	questions, errs := parseGIFT(strings.NewReader(input))
+	if len(errs) > 0 {
+		t.Fatalf("parseGIFT() returned errors: %v", errs)
+	}
+	if len(questions) != 4 {
+		t.Fatalf("got %d questions, want 4", len(questions))
+	}
+
+	tests := []struct {
+		line        int
+		question    string
+		choices     string
+		answer      int
+		explanation string
+	}{
+		{4, "What is the capital of France?", "London|Paris|Berlin", 1, "Correct"},
+		{10, "The Seine flows through Paris.", "True|False", 0, "Right, it does"},
+		{12, "Two plus two equals _____ in base ten.", "three|four|five", 1, ""},
+		{14, "What does {x} mean in set notation?", "a set containing x|a function = x", 0, "Curly braces enclose set members."},
+	}
+	for i, tt := range tests {
+		q := questions[i]
+		if q.Line != tt.line || q.Question.Question != tt.question || strings.Join(q.Choices, "|") != tt.choices ||
+			q.AnswerIndex != tt.answer || q.Explanation != tt.explanation {
+			t.Errorf("question %d = %+v, want %+v", i, q, tt)
+		}
+		if len(q.Tags) != 1 || q.Tags[0] != "geography" {
+			t.Errorf("question %d tags = %v, want the category", i, q.Tags)
+		}
+	}
+}
+
+func TestParseGIFTUnsupported(t *testing.T) {
+	tests := map[string]string{
+		"Who wrote Hamlet? {=Shakespeare =William Shakespeare}": "line 1: short answer questions are not supported",
+		"Pick two. {~%50%A ~%50%B ~%-100%C}":                    "line 1: weighted answers are not supported",
+		"Match. {=cat -> meow =dog -> woof}":                    "line 1: matching questions are not supported",
+		"What is pi? {#3.14:0.01}":                              "line 1: numerical questions are not supported",
+		"No answers here.":                                      "line 1: question has no {answers}",
+		"Which? {~A ~B}":                                        "line 1: no correct answer marked with =",
+	}
+	for input, want := range tests {
+		_, errs := parseGIFT(strings.NewReader(input))
+		if len(errs) != 1 || errs[0].Error() != want {
+			t.Errorf("parseGIFT(%q) errors = %v, want %q", input, errs, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// answerHistoryPath is the JSON-lines file every scored answer is appended to
+var answerHistoryPath = "answer_history.jsonl"
+
+// historyMux serializes appends to the answer history file
+var historyMux sync.Mutex
+
+// AnswerEvent is a single scored answer as recorded in the answer history
+type AnswerEvent struct {
+	SessionID  string     `json:"session_id"`
+	PlayerID   string     `json:"player_id,omitempty"`
+	QuestionID int        `json:"question_id"`
+	Choice     int        `json:"choice"`
+	Correct    bool       `json:"correct"`
+	Confidence Confidence `json:"confidence,omitempty"`
+	LatencyMs  int64      `json:"latency_ms"`
+	Time       time.Time  `json:"time"`
+	// QuizSlug and Lifelines are what is needed besides the answer to
+	// score it again under the quiz's scoring rules
+	QuizSlug  string     `json:"quiz,omitempty"`
+	Lifelines []Lifeline `json:"lifelines,omitempty"`
+
+	// QuestionVersion is the version of the question that was served, and
+	// GradedVersion the version the answer was last regraded against
+	QuestionVersion int `json:"question_version,omitempty"`
+	GradedVersion   int `json:"graded_version,omitempty"`
+
+	// VariantSeed regenerates the variant that was answered when the
+	// question is a template; Choice indexes that variant's choices
+	VariantSeed int64 `json:"variant_seed,omitempty"`
+}
+
+// appendAnswerEvent adds an event to the end of the answer history file
+func appendAnswerEvent(event AnswerEvent) error {
+	historyMux.Lock()
+	defer historyMux.Unlock()
+	return appendJSONLine(answerHistoryPath, event)
+}
+
+// writeAnswerHistory atomically replaces the answer history file with events.
+// Callers must hold historyMux.
+func writeAnswerHistory(path string, events []AnswerEvent) error {
+	var buf bytes.Buffer
+	for _, e := range events {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// readAnswerHistory loads every event from a JSON-lines answer history file
+func readAnswerHistory(path string) ([]AnswerEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []AnswerEvent
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event AnswerEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestMain points file-backed stores at a temporary directory so tests that
+// exercise the handlers never write into the working tree
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "maestro-demo-test")
+	if err != nil {
+		panic(err)
+	}
+	answerHistoryPath = filepath.Join(dir, "answer_history.jsonl")
+	studyStatePath = filepath.Join(dir, "study_state.json")
+	seenQuestionsPath = filepath.Join(dir, "seen_questions.json")
+	attemptsPath = filepath.Join(dir, "attempts.json")
+	questionVersionsPath = filepath.Join(dir, "question_versions.json")
+	regradeLogPath = filepath.Join(dir, "regrades.jsonl")
+	submissionsPath = filepath.Join(dir, "submissions.json")
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestAnswerHistoryRoundTrip(t *testing.T) {
+	path := answerHistoryPath
+	answerHistoryPath = filepath.Join(t.TempDir(), "history.jsonl")
+	defer func() { answerHistoryPath = path }()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	want := []AnswerEvent{
+		{SessionID: "s1", QuestionID: 1, Choice: 2, Correct: true, Time: now},
+		{SessionID: "s1", QuestionID: 2, Choice: 0, Correct: false, Confidence: ConfidenceHigh, Time: now},
+	}
+	for _, e := range want {
+		if err := appendAnswerEvent(e); err != nil {
+			t.Fatalf("appendAnswerEvent() returned error: %v", err)
+		}
+	}
+
+	got, err := readAnswerHistory(answerHistoryPath)
+	if err != nil {
+		t.Fatalf("readAnswerHistory() returned error: %v", err)
+	}
+	if len(got) != len(want) || !reflect.DeepEqual(got[1], want[1]) {
+		t.Errorf("readAnswerHistory() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAnswerHandlerRecordsFirstAttemptOnly(t *testing.T) {
+	path := answerHistoryPath
+	answerHistoryPath = filepath.Join(t.TempDir(), "history.jsonl")
+	defer func() { answerHistoryPath = path }()
+
+	if err := os.WriteFile("quiz.html", []byte(`{{.Question.Question}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.html")
+
+	session := newTestSession(t, []Question{
+		{ID: 7, Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 1},
+		{ID: 8, Question: "Q2?", Choices: []string{"A", "B"}, AnswerIndex: 1},
+	})
+	session.Mode = ModePractice
+
+	postAnswer(session.ID, 0, 0)
+	postAnswer(session.ID, 0, 1)
+
+	events, err := readAnswerHistory(answerHistoryPath)
+	if err != nil {
+		t.Fatalf("readAnswerHistory() returned error: %v", err)
+	}
+	if len(events) != 1 || events[0].QuestionID != 7 || events[0].Correct {
+		t.Errorf("expected only the first, incorrect attempt to be recorded, got %+v", events)
+	}
+}
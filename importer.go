@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Import formats supported by the import command
+const (
+	formatCSV  = "csv"
+	formatYAML = "yaml"
+	formatGIFT = "gift"
+)
+
+// importedQuestion is a question read from an import file, remembering where
+// it came from for error messages
+type importedQuestion struct {
+	Question
+	Source string
+	Line   int
+	// HasID is set when the file gave the question an explicit ID
+	HasID bool
+}
+
+// lineError is a problem found at a line of an import file
+type lineError struct {
+	Line int
+	Err  error
+}
+
+func (e lineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// sourceError prefixes an import error with the file it was found in, in
+// the file:line: form editors understand
+func sourceError(path string, err error) error {
+	var le lineError
+	if errors.As(err, &le) {
+		return fmt.Errorf("%s:%d: %v", path, le.Line, le.Err)
+	}
+	return fmt.Errorf("%s: %v", path, err)
+}
+
+// field is one value of a question record in a CSV or YAML file. Scalars
+// have a single value; lists may have any number.
+type field struct {
+	Line   int
+	Values []string
+	IsList bool
+}
+
+// record is a question as read from a CSV row or YAML list item, before its
+// fields are converted
+type record struct {
+	Line   int
+	Fields map[string]field
+}
+
+// recordKeys are the fields a CSV or YAML question record may use, matching
+// the questions.json schema
+var recordKeys = map[string]bool{
+	"id": true, "question": true, "choices": true, "answer_index": true,
+	"explanation": true, "hint": true, "tags": true,
+	"difficulty": true, "discrimination": true,
+}
+
+// questionFromRecord converts a record to a question, reporting each bad
+// field at its own line
+func questionFromRecord(rec record) (importedQuestion, []error) {
+	q := importedQuestion{Line: rec.Line}
+	var errs []error
+	scalar := func(key string) (string, int, bool) {
+		f, ok := rec.Fields[key]
+		if !ok {
+			return "", rec.Line, false
+		}
+		if f.IsList {
+			errs = append(errs, lineError{f.Line, fmt.Errorf("%s must be a single value, not a list", key)})
+			return "", f.Line, false
+		}
+		return f.Values[0], f.Line, true
+	}
+
+	for key, f := range rec.Fields {
+		if !recordKeys[key] {
+			errs = append(errs, lineError{f.Line, fmt.Errorf("unknown field %q", key)})
+		}
+	}
+
+	if v, line, ok := scalar("id"); ok && v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			errs = append(errs, lineError{line, fmt.Errorf("id %q must be a positive integer", v)})
+		}
+		q.ID, q.HasID = id, true
+	}
+
+	if v, _, _ := scalar("question"); v != "" {
+		q.Question.Question = v
+	} else {
+		errs = append(errs, lineError{rec.Line, errors.New("question text is missing")})
+	}
+
+	if f, ok := rec.Fields["choices"]; ok {
+		for _, c := range f.Values {
+			if c != "" {
+				q.Choices = append(q.Choices, c)
+			}
+		}
+	}
+
+	if v, line, ok := scalar("answer_index"); ok && v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, lineError{line, fmt.Errorf("answer_index %q must be an integer", v)})
+		}
+		q.AnswerIndex = i
+	} else {
+		errs = append(errs, lineError{rec.Line, errors.New("answer_index is missing")})
+	}
+
+	q.Explanation, _, _ = scalar("explanation")
+	q.Hint, _, _ = scalar("hint")
+
+	if f, ok := rec.Fields["tags"]; ok {
+		for _, t := range f.Values {
+			if t = strings.TrimSpace(t); t != "" {
+				q.Tags = append(q.Tags, t)
+			}
+		}
+	}
+
+	for key, dst := range map[string]*float64{"difficulty": &q.Difficulty, "discrimination": &q.Discrimination} {
+		if v, line, ok := scalar(key); ok && v != "" {
+			x, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				errs = append(errs, lineError{line, fmt.Errorf("%s %q must be a number", key, v)})
+			}
+			*dst = x
+		}
+	}
+	return q, errs
+}
+
+// parseCSV reads questions from a spreadsheet export. The header row names
+// the columns after the questions.json fields; every column whose name
+// starts with "choice" holds one choice, and tags are separated by
+// semicolons.
+func parseCSV(r io.Reader) ([]importedQuestion, []error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, []error{csvError(err)}
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	var questions []importedQuestion
+	var errs []error
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, csvError(err))
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		rec := record{Line: line, Fields: make(map[string]field)}
+		choices := field{Line: line, IsList: true}
+		for i, name := range header {
+			value := strings.TrimSpace(row[i])
+			switch {
+			case strings.HasPrefix(name, "choice"):
+				choices.Values = append(choices.Values, value)
+			case name == "tags":
+				rec.Fields[name] = field{Line: line, Values: strings.Split(value, ";"), IsList: true}
+			case value != "" || name == "answer_index":
+				rec.Fields[name] = field{Line: line, Values: []string{value}}
+			}
+		}
+		rec.Fields["choices"] = choices
+
+		q, qerrs := questionFromRecord(rec)
+		questions = append(questions, q)
+		errs = append(errs, qerrs...)
+	}
+	return questions, errs
+}
+
+// csvError converts a CSV parse error to a lineError
+func csvError(err error) error {
+	var pe *csv.ParseError
+	if errors.As(err, &pe) {
+		return lineError{pe.Line, pe.Err}
+	}
+	return err
+}
+
+// stableQuestionID derives a question ID from its text, so importing the
+// same question again gives it the same ID
+func stableQuestionID(text string) int {
+	h := fnv.New32a()
+	h.Write([]byte(strings.Join(strings.Fields(strings.ToLower(text)), " ")))
+	id := int(h.Sum32() & 0x7fffffff)
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
+
+// importFormat picks the format for a file from the -format flag or, when
+// that is empty, the file's extension
+func importFormat(path, format string) (string, error) {
+	if format != "" {
+		switch format {
+		case formatCSV, formatYAML, formatGIFT:
+			return format, nil
+		}
+		return "", fmt.Errorf("unknown format %q (want %q, %q or %q)", format, formatCSV, formatYAML, formatGIFT)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return formatCSV, nil
+	case ".yaml", ".yml":
+		return formatYAML, nil
+	case ".gift", ".txt":
+		return formatGIFT, nil
+	}
+	return "", fmt.Errorf("%s: cannot tell the format from the extension; use -format", path)
+}
+
+// importFile parses the questions in one file, prefixing errors with the
+// file name
+func importFile(path, format string) ([]importedQuestion, []error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, []error{fmt.Errorf("import: %w", err)}
+	}
+	defer f.Close()
+
+	var questions []importedQuestion
+	var errs []error
+	switch format {
+	case formatCSV:
+		questions, errs = parseCSV(f)
+	case formatYAML:
+		questions, errs = parseYAML(f)
+	case formatGIFT:
+		questions, errs = parseGIFT(f)
+	}
+
+	for i := range questions {
+		questions[i].Source = path
+	}
+	for i, err := range errs {
+		errs[i] = sourceError(path, err)
+	}
+	return questions, errs
+}
+
+// finishImport assigns stable IDs to questions without one and reports the
+// lint errors that would stop loadQuestions, including duplicate IDs
+func finishImport(questions []importedQuestion) []error {
+	var errs []error
+	seen := make(map[int]importedQuestion)
+	for i := range questions {
+		q := &questions[i]
+		if !q.HasID {
+			q.ID = stableQuestionID(q.Question.Question)
+		}
+		for _, issue := range lintQuestion(q.Question) {
+			if issue.Severity == severityError {
+				errs = append(errs, sourceError(q.Source, lineError{q.Line, errors.New(issue.Message)}))
+			}
+		}
+		if prev, ok := seen[q.ID]; ok {
+			errs = append(errs, sourceError(q.Source, lineError{q.Line, fmt.Errorf("id %d is already used by %s line %d", q.ID, prev.Source, prev.Line)}))
+			continue
+		}
+		seen[q.ID] = *q
+	}
+	return errs
+}
+
+// mergeQuestions replaces questions in the bank that share an ID with an
+// imported one, bumping their version if they changed, and appends the rest,
+// returning how many were added
+func mergeQuestions(bank []Question, imported []importedQuestion) ([]Question, int) {
+	index := make(map[int]int, len(bank))
+	for i, q := range bank {
+		index[q.ID] = i
+	}
+	added := 0
+	for _, q := range imported {
+		if i, ok := index[q.ID]; ok {
+			bank[i] = bumpVersion(bank[i], q.Question)
+			continue
+		}
+		bank = append(bank, q.Question)
+		added++
+	}
+	return bank, added
+}
+
+// runImport implements the import command, which converts CSV, YAML and GIFT
+// question files into the questions.json schema and merges them into the
+// question bank. Nothing is written if any file has errors.
+func runImport(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "", "input format: csv, yaml or gift (default: from the file extension)")
+	out := fs.String("out", "questions.json", "question bank to merge the imported questions into")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "import: no input files")
+		return 2
+	}
+
+	var imported []importedQuestion
+	var errs []error
+	for _, path := range fs.Args() {
+		f, err := importFormat(path, *format)
+		if err != nil {
+			fmt.Fprintf(stderr, "import: %v\n", err)
+			return 2
+		}
+		questions, ferrs := importFile(path, f)
+		imported = append(imported, questions...)
+		errs = append(errs, ferrs...)
+	}
+	if len(errs) == 0 {
+		errs = finishImport(imported)
+	}
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintln(stderr, err)
+		}
+		fmt.Fprintf(stderr, "import: %d errors; %s was not changed\n", len(errs), *out)
+		return 1
+	}
+
+	bank, err := readQuestionBank(*out)
+	if err != nil {
+		fmt.Fprintf(stderr, "import: %v\n", err)
+		return 1
+	}
+	bank, added := mergeQuestions(bank, imported)
+	if err := writeQuestionBank(*out, bank); err != nil {
+		fmt.Fprintf(stderr, "import: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Imported %d questions (%d new, %d updated) into %s\n", len(imported), added, len(imported)-added, *out)
+	return 0
+}
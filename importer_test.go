@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCSV(t *testing.T) {
+	input := `id,question,choice1,choice2,choice3,answer_index,explanation,tags
+7,What is 2 + 2?,3,4,,1,Basic addition,math;easy
+,"Which is a primary colour, in paint?",Red,Green,Purple,0,,
+`
+	questions, errs := parseCSV(strings.NewReader(input))
+	if len(errs) > 0 {
+		t.Fatalf("parseCSV() returned errors: %v", errs)
+	}
+	if len(questions) != 2 {
+		t.Fatalf("got %d questions, want 2", len(questions))
+	}
+
+	q := questions[0]
+	if q.ID != 7 || !q.HasID || len(q.Choices) != 2 || q.AnswerIndex != 1 || q.Explanation != "Basic addition" {
+		t.Errorf("first question = %+v", q)
+	}
+	if len(q.Tags) != 2 || q.Tags[0] != "math" || q.Tags[1] != "easy" {
+		t.Errorf("tags = %v, want [math easy]", q.Tags)
+	}
+	if q := questions[1]; q.HasID || q.Line != 3 || q.Question.Question != "Which is a primary colour, in paint?" || len(q.Tags) != 0 {
+		t.Errorf("second question = %+v", q)
+	}
+
+	bad := `question,choice1,choice2,answer_index,colour
+What?,A,B,x,red
+`
+	_, errs = parseCSV(strings.NewReader(bad))
+	want := []string{`line 2: unknown field "colour"`, `line 2: answer_index "x" must be an integer`}
+	for _, w := range want {
+		found := false
+		for _, err := range errs {
+			found = found || err.Error() == w
+		}
+		if !found {
+			t.Errorf("errors %v should include %q", errs, w)
+		}
+	}
+}
+
+func TestFinishImport(t *testing.T) {
+	questions := []importedQuestion{
+		{Question: Question{Question: "What is 2 + 2?", Choices: []string{"3", "4"}, AnswerIndex: 1}, Source: "a.csv", Line: 2},
+		{Question: Question{Question: "Out of range?", Choices: []string{"A", "B"}, AnswerIndex: 2}, Source: "a.csv", Line: 3},
+		{Question: Question{ID: 5, Question: "Five?", Choices: []string{"A", "B"}}, Source: "b.yaml", Line: 1, HasID: true},
+		{Question: Question{ID: 5, Question: "Also five?", Choices: []string{"A", "B"}}, Source: "b.yaml", Line: 9, HasID: true},
+	}
+	errs := finishImport(questions)
+
+	if questions[0].ID != stableQuestionID("what is  2 + 2?") {
+		t.Errorf("ID should be derived from the normalized text, got %d", questions[0].ID)
+	}
+	want := []string{
+		"a.csv:3: correct index 2 is out of bounds for answers array of length 2",
+		"b.yaml:9: id 5 is already used by b.yaml line 1",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %v, want %v", errs, want)
+	}
+	for i := range want {
+		if errs[i].Error() != want[i] {
+			t.Errorf("error %d = %q, want %q", i, errs[i], want[i])
+		}
+	}
+}
+
+func TestRunImport(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "questions.json")
+	existing := `[{"id": 1, "question": "Kept?", "choices": ["A", "B"], "answer_index": 0}]`
+	if err := os.WriteFile(out, []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	csvPath := filepath.Join(dir, "bank.csv")
+	if err := os.WriteFile(csvPath, []byte("question,choice1,choice2,answer_index\nNew?,A,B,1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	giftPath := filepath.Join(dir, "bank.gift")
+	if err := os.WriteFile(giftPath, []byte("::Q1:: The sky is blue. {T}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runCommand([]string{"import", "-out", out, csvPath, giftPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("import exited %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Imported 2 questions (2 new, 0 updated)") {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+
+	// Importing again updates the same questions instead of adding copies
+	stdout.Reset()
+	if code := runCommand([]string{"import", "-out", out, csvPath, giftPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("second import exited %d: %s", code, stderr.String())
+	}
+	bank, err := readQuestionBank(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bank) != 3 || bank[0].Question != "Kept?" || !strings.Contains(stdout.String(), "(0 new, 2 updated)") {
+		t.Errorf("re-import should keep stable IDs, got %d questions and output %q", len(bank), stdout.String())
+	}
+
+	// A file with errors reports them with line numbers and writes nothing
+	if err := os.WriteFile(csvPath, []byte("question,choice1,answer_index\nBroken?,A,3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stderr.Reset()
+	if code := runCommand([]string{"import", "-out", out, csvPath}, &stdout, &stderr); code != 1 {
+		t.Errorf("import of a bad file exited %d, want 1", code)
+	}
+	if !strings.Contains(stderr.String(), csvPath+":2: correct index 3 is out of bounds") {
+		t.Errorf("stderr = %q, want a line-numbered bounds error", stderr.String())
+	}
+}
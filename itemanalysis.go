@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+)
+
+// itemFlagMinResponses is how many answers a question needs before the
+// report raises distractor and key flags for it
+const itemFlagMinResponses = 20
+
+// upperGroupFraction is the share of top-scoring sessions treated as strong
+// players when checking whether they miss a question's key
+const upperGroupFraction = 0.27
+
+// ChoiceStat reports how often one choice of a question was picked
+type ChoiceStat struct {
+	Index      int     `json:"index"`
+	Text       string  `json:"text"`
+	IsKey      bool    `json:"is_key"`
+	Count      int     `json:"count"`
+	Percent    float64 `json:"percent"`
+	UpperCount int     `json:"upper_count"`
+}
+
+// ItemAnalysis is the classical test theory report for one question
+type ItemAnalysis struct {
+	ID            int          `json:"id"`
+	Question      string       `json:"question"`
+	Responses     int          `json:"responses"`
+	PValue        float64      `json:"p_value"`
+	PointBiserial float64      `json:"point_biserial"`
+	MeanLatencyMs float64      `json:"mean_latency_ms"`
+	Choices       []ChoiceStat `json:"choices"`
+	Flags         []string     `json:"flags,omitempty"`
+}
+
+// analyzeItems computes per-question difficulty (p-value), corrected
+// point-biserial discrimination, choice distribution and latency from the
+// answer history, and flags distractors nobody picks and keys that strong
+// players miss
+func analyzeItems(events []AnswerEvent, questions []Question) []ItemAnalysis {
+	// Total correct per session, used as the ability measure
+	totals := make(map[string]int)
+	for _, e := range events {
+		if e.Correct {
+			totals[e.SessionID]++
+		}
+	}
+
+	byQuestion := make(map[int][]AnswerEvent)
+	for _, e := range events {
+		byQuestion[e.QuestionID] = append(byQuestion[e.QuestionID], e)
+	}
+
+	report := make([]ItemAnalysis, 0, len(questions))
+	for _, q := range questions {
+		report = append(report, analyzeItem(q, byQuestion[q.ID], totals))
+	}
+	return report
+}
+
+// analyzeItem builds the report for a single question from its answers
+func analyzeItem(q Question, events []AnswerEvent, totals map[string]int) ItemAnalysis {
+	item := ItemAnalysis{ID: q.ID, Question: q.Question, Responses: len(events)}
+	for i, text := range q.Choices {
+		item.Choices = append(item.Choices, ChoiceStat{Index: i, Text: text, IsKey: i == q.AnswerIndex})
+	}
+	if len(events) == 0 {
+		return item
+	}
+
+	// Rest score excludes this question so it does not inflate its own
+	// discrimination
+	rest := make([]float64, len(events))
+	var correct int
+	var latency float64
+	for i, e := range events {
+		rest[i] = float64(totals[e.SessionID])
+		if e.Correct {
+			correct++
+			rest[i]--
+		}
+		latency += float64(e.LatencyMs)
+		if e.Choice >= 0 && e.Choice < len(item.Choices) {
+			item.Choices[e.Choice].Count++
+		}
+	}
+
+	n := float64(len(events))
+	item.PValue = round(float64(correct)/n, 3)
+	item.MeanLatencyMs = round(latency/n, 1)
+	item.PointBiserial = round(pointBiserial(events, rest), 3)
+	for i := range item.Choices {
+		item.Choices[i].Percent = round(float64(item.Choices[i].Count)*100/n, 1)
+	}
+
+	// Strong players are the top-scoring sessions by rest score
+	order := make([]int, len(events))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return rest[order[a]] > rest[order[b]] })
+	upper := int(math.Ceil(n * upperGroupFraction))
+	for _, i := range order[:upper] {
+		if c := events[i].Choice; c >= 0 && c < len(item.Choices) {
+			item.Choices[c].UpperCount++
+		}
+	}
+
+	if len(events) < itemFlagMinResponses {
+		return item
+	}
+
+	if item.PointBiserial < 0 {
+		item.Flags = append(item.Flags, fmt.Sprintf("negative discrimination (%.2f): weaker players answer correctly more often", item.PointBiserial))
+	}
+	// Template questions have different choices in every variant, so there
+	// is no key or distractor to compare across answers
+	if q.Template != nil {
+		return item
+	}
+	key := item.Choices[q.AnswerIndex]
+	for _, c := range item.Choices {
+		if c.IsKey {
+			continue
+		}
+		if c.Count == 0 {
+			item.Flags = append(item.Flags, fmt.Sprintf("distractor %q was never chosen", c.Text))
+		}
+		if c.UpperCount > key.UpperCount {
+			item.Flags = append(item.Flags, fmt.Sprintf("strong players chose %q over the key %q; check the answer key", c.Text, key.Text))
+		}
+	}
+	return item
+}
+
+// pointBiserial returns the correlation between answering correctly and the
+// rest score, or 0 when either does not vary
+func pointBiserial(events []AnswerEvent, rest []float64) float64 {
+	var sum1, sum0, n1, n0, mean float64
+	for i, e := range events {
+		mean += rest[i]
+		if e.Correct {
+			sum1 += rest[i]
+			n1++
+		} else {
+			sum0 += rest[i]
+			n0++
+		}
+	}
+	n := n1 + n0
+	mean /= n
+
+	var variance float64
+	for _, r := range rest {
+		variance += (r - mean) * (r - mean)
+	}
+	sd := math.Sqrt(variance / n)
+	if sd == 0 || n1 == 0 || n0 == 0 {
+		return 0
+	}
+
+	p := n1 / n
+	return (sum1/n1 - sum0/n0) / sd * math.Sqrt(p*(1-p))
+}
+
+// adminReportHandler handles GET /admin/report, rendering the item analysis
+// for every question in the bank. ?format=json returns the raw report for
+// tooling.
+func adminReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	questions, err := loadQuestions()
+	if err != nil {
+		log.Printf("Error loading questions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	events, err := readAnswerHistory(answerHistoryPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Error reading answer history: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	report := analyzeItems(events, questions)
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			log.Printf("Error encoding report: %v", err)
+		}
+		return
+	}
+
+	tmpl, err := template.ParseFiles("admin_report.html")
+	if err != nil {
+		log.Printf("Error parsing template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, report); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// itemAnalysisEvents builds a history of 30 sessions. Questions 4 and 5 are
+// anchors only the stronger half answers correctly, and question 1 separates
+// players the same way. On question 2 the stronger half all pick choice 2
+// instead of the key, and choice 3 is never picked.
+func itemAnalysisEvents() []AnswerEvent {
+	var events []AnswerEvent
+	for s := 0; s < 30; s++ {
+		id := fmt.Sprintf("s%d", s)
+		strong := s < 15
+		q1Choice := 1
+		if strong {
+			q1Choice = 0
+		}
+		events = append(events, AnswerEvent{SessionID: id, QuestionID: 1, Choice: q1Choice, Correct: strong, LatencyMs: 1000})
+
+		q2Choice := 0
+		if strong {
+			q2Choice = 2
+		} else if s%2 == 0 {
+			q2Choice = 1
+		}
+		events = append(events, AnswerEvent{SessionID: id, QuestionID: 2, Choice: q2Choice, Correct: q2Choice == 0, LatencyMs: 3000})
+
+		for _, anchor := range []int{4, 5} {
+			events = append(events, AnswerEvent{SessionID: id, QuestionID: anchor, Correct: strong})
+		}
+	}
+	return events
+}
+
+func TestAnalyzeItems(t *testing.T) {
+	questions := []Question{
+		{ID: 1, Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+		{ID: 2, Question: "Q2?", Choices: []string{"A", "B", "C", "D"}, AnswerIndex: 0},
+		{ID: 3, Question: "Unused?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+	}
+
+	report := analyzeItems(itemAnalysisEvents(), questions)
+	if len(report) != 3 {
+		t.Fatalf("analyzeItems() returned %d items, want 3", len(report))
+	}
+
+	q1, q2, q3 := report[0], report[1], report[2]
+	if q1.PValue != 0.5 || q1.PointBiserial <= 0 || len(q1.Flags) != 0 {
+		t.Errorf("question 1 should discriminate cleanly with no flags, got %+v", q1)
+	}
+	if q1.MeanLatencyMs != 1000 {
+		t.Errorf("question 1 mean latency = %v, want 1000", q1.MeanLatencyMs)
+	}
+
+	if q2.Choices[2].Count != 15 || q2.Choices[3].Count != 0 || q2.Choices[2].Percent != 50 {
+		t.Errorf("unexpected choice distribution for question 2: %+v", q2.Choices)
+	}
+	flags := strings.Join(q2.Flags, "\n")
+	for _, want := range []string{`distractor "D" was never chosen`, `strong players chose "C" over the key "A"`, "negative discrimination"} {
+		if !strings.Contains(flags, want) {
+			t.Errorf("question 2 flags missing %q, got %q", want, flags)
+		}
+	}
+
+	if q3.Responses != 0 || q3.Flags != nil {
+		t.Errorf("unanswered question should have an empty report, got %+v", q3)
+	}
+}
+
+func TestAdminReportHandlerRequiresAuth(t *testing.T) {
+	handler := requireAdmin(adminReportHandler)
+
+	t.Setenv("ADMIN_PASSWORD", "")
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, "/admin/report", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("admin without a configured password returned %d, want %d", rr.Code, http.StatusForbidden)
+	}
+
+	t.Setenv("ADMIN_PASSWORD", "secret")
+	req := httptest.NewRequest(http.MethodGet, "/admin/report", nil)
+	req.SetBasicAuth("admin", "wrong")
+	rr = httptest.NewRecorder()
+	handler(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("admin with a wrong password returned %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAdminReportHandlerJSON(t *testing.T) {
+	t.Setenv("ADMIN_PASSWORD", "secret")
+
+	path := answerHistoryPath
+	answerHistoryPath = filepath.Join(t.TempDir(), "history.jsonl")
+	defer func() { answerHistoryPath = path }()
+	for _, e := range itemAnalysisEvents() {
+		if err := appendAnswerEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	questionsJSON := `[{"id":1,"question":"Q1?","choices":["A","B"],"answer_index":0},{"id":2,"question":"Q2?","choices":["A","B","C","D"],"answer_index":0}]`
+	if err := os.WriteFile("questions.json", []byte(questionsJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("questions.json")
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/report?format=json", nil)
+	req.SetBasicAuth("admin", "secret")
+	rr := httptest.NewRecorder()
+	requireAdmin(adminReportHandler)(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("report returned status %d, want %d", rr.Code, http.StatusOK)
+	}
+	var report []ItemAnalysis
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	if len(report) != 2 || report[1].Responses != 30 || len(report[1].Flags) == 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
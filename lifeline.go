@@ -0,0 +1,195 @@
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Lifeline identifies a kind of help a player can ask for on a question
+type Lifeline string
+
+const (
+	// LifelineFiftyFifty removes two wrong choices from the current question
+	LifelineFiftyFifty Lifeline = "fifty_fifty"
+	// LifelineHint reveals the current question's hint text
+	LifelineHint Lifeline = "hint"
+)
+
+// LifelineLimits sets how many times each lifeline may be used per session
+type LifelineLimits struct {
+	FiftyFifty int `json:"fifty_fifty"`
+	Hint       int `json:"hint"`
+}
+
+// limit returns the per-session allowance for the given lifeline
+func (l LifelineLimits) limit(kind Lifeline) int {
+	switch kind {
+	case LifelineFiftyFifty:
+		return l.FiftyFifty
+	case LifelineHint:
+		return l.Hint
+	default:
+		return 0
+	}
+}
+
+// LifelineUse records a lifeline spent on one of the session's questions
+type LifelineUse struct {
+	QuestionIndex int
+	Kind          Lifeline
+	// Removed holds the choice indices hidden by a fifty-fifty
+	Removed []int
+}
+
+// lifelinesFor returns the lifelines used on the question at index
+func (s *QuizSession) lifelinesFor(index int) []Lifeline {
+	var used []Lifeline
+	for _, u := range s.Lifelines {
+		if u.QuestionIndex == index {
+			used = append(used, u.Kind)
+		}
+	}
+	return used
+}
+
+// lifelinesRemaining returns how many more times kind may be used
+func (s *QuizSession) lifelinesRemaining(kind Lifeline) int {
+	remaining := s.LifelineLimits.limit(kind)
+	for _, u := range s.Lifelines {
+		if u.Kind == kind {
+			remaining--
+		}
+	}
+	return remaining
+}
+
+// removedChoices returns the choice indices a fifty-fifty hid on the question
+// at index, or nil if none was used
+func (s *QuizSession) removedChoices(index int) []int {
+	for _, u := range s.Lifelines {
+		if u.QuestionIndex == index && u.Kind == LifelineFiftyFifty {
+			return u.Removed
+		}
+	}
+	return nil
+}
+
+// fiftyFiftyRemovals picks wrong choices to hide, always leaving the correct
+// answer and at least one wrong one. It returns nil when there are too few
+// choices for the lifeline to help.
+func fiftyFiftyRemovals(q Question) []int {
+	var wrong []int
+	for i := range q.Choices {
+		if i != q.AnswerIndex {
+			wrong = append(wrong, i)
+		}
+	}
+
+	n := len(wrong) - 1
+	if n > 2 {
+		n = 2
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	r.Shuffle(len(wrong), func(i, j int) {
+		wrong[i], wrong[j] = wrong[j], wrong[i]
+	})
+	return wrong[:n]
+}
+
+// lifelineHandler handles the POST /lifeline endpoint, spending one of the
+// session's lifelines on the current question and re-rendering it
+func lifelineHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	sessionID := r.FormValue("sessionID")
+	questionIndex, err := strconv.Atoi(r.FormValue("questionIndex"))
+	if err != nil {
+		http.Error(w, "Invalid question index", http.StatusBadRequest)
+		return
+	}
+
+	if !verifyState(sessionID, questionIndex, r.FormValue("hmacSignature")) {
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	}
+
+	kind := Lifeline(r.FormValue("lifeline"))
+	if kind != LifelineFiftyFifty && kind != LifelineHint {
+		http.Error(w, "Unknown lifeline", http.StatusBadRequest)
+		return
+	}
+
+	data, status, message := useLifeline(sessionID, questionIndex, kind)
+	if status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+
+	renderQuestion(w, data)
+}
+
+// useLifeline spends a lifeline on the session's current question and returns
+// the refreshed question page, or an HTTP status and message on failure
+func useLifeline(sessionID string, questionIndex int, kind Lifeline) (questionPage, int, string) {
+	sessionMux.Lock()
+	defer sessionMux.Unlock()
+
+	session, ok := sessions[sessionID]
+	if !ok {
+		return questionPage{}, http.StatusNotFound, "Session not found"
+	}
+
+	session.checkDeadline(time.Now())
+	if session.Finished() || questionIndex != session.Current {
+		return questionPage{}, http.StatusConflict, "Question already answered"
+	}
+
+	if session.awaitingSection() {
+		return questionPage{}, http.StatusConflict, "Section not started"
+	}
+
+	if session.paused(time.Now()) {
+		return questionPage{}, http.StatusConflict, "Quiz is paused"
+	}
+
+	for _, used := range session.lifelinesFor(questionIndex) {
+		if used == kind {
+			return questionPage{}, http.StatusConflict, "Lifeline already used on this question"
+		}
+	}
+
+	if session.lifelinesRemaining(kind) <= 0 {
+		return questionPage{}, http.StatusConflict, "No lifelines of this kind remaining"
+	}
+
+	use := LifelineUse{QuestionIndex: questionIndex, Kind: kind}
+	question := session.Questions[questionIndex]
+	switch kind {
+	case LifelineFiftyFifty:
+		use.Removed = fiftyFiftyRemovals(question)
+		if use.Removed == nil {
+			return questionPage{}, http.StatusConflict, "Not enough choices for fifty-fifty"
+		}
+	case LifelineHint:
+		if question.Hint == "" {
+			return questionPage{}, http.StatusConflict, "This question has no hint"
+		}
+	}
+	session.Lifelines = append(session.Lifelines, use)
+
+	return newQuestionPage(session), http.StatusOK, ""
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// postLifeline requests a lifeline for the given question index through lifelineHandler
+func postLifeline(sessionID string, questionIndex int, kind Lifeline) *httptest.ResponseRecorder {
+	form := url.Values{}
+	form.Set("sessionID", sessionID)
+	form.Set("questionIndex", strconv.Itoa(questionIndex))
+	form.Set("hmacSignature", signState(sessionID, questionIndex))
+	form.Set("lifeline", string(kind))
+
+	req := httptest.NewRequest(http.MethodPost, "/lifeline", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	lifelineHandler(rr, req)
+	return rr
+}
+
+func TestLifelineHintAndFiftyFifty(t *testing.T) {
+	quizHTML := `<h1>{{.Question.Question}}</h1>{{if .Hint}}Hint: {{.Hint}}{{end}}
+{{range .Choices}}{{if not .Removed}}<li>{{.Text}}</li>{{end}}{{end}}`
+	if err := os.WriteFile("quiz.html", []byte(quizHTML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.html")
+
+	session := newTestSession(t, []Question{
+		{ID: 1, Question: "Q1?", Choices: []string{"A", "B", "C", "D"}, AnswerIndex: 2, Hint: "Third letter"},
+		{ID: 2, Question: "Q2?", Choices: []string{"A", "B", "C", "D"}, AnswerIndex: 0, Hint: "First letter"},
+	})
+
+	rr := postLifeline(session.ID, 0, LifelineHint)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Hint: Third letter") {
+		t.Fatalf("hint lifeline should reveal the hint, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = postLifeline(session.ID, 0, LifelineFiftyFifty)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("fifty-fifty returned status %d, want %d", rr.Code, http.StatusOK)
+	}
+	if got := strings.Count(rr.Body.String(), "<li>"); got != 2 {
+		t.Errorf("fifty-fifty should leave 2 choices, got %d in %q", got, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "<li>C</li>") {
+		t.Errorf("fifty-fifty must keep the correct choice, got %q", rr.Body.String())
+	}
+
+	removed := session.removedChoices(0)[0]
+	if rr := postAnswer(session.ID, 0, removed); rr.Code != http.StatusBadRequest {
+		t.Errorf("answering with a removed choice returned %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+
+	postAnswer(session.ID, 0, 2)
+	if session.Answers[0].Points != 0 || session.Score != 0 {
+		t.Errorf("lifeline-assisted answer should be penalized to 0 points, got %+v", session.Answers[0])
+	}
+
+	// Both lifelines were limited to one use per session by the default definition
+	if rr := postLifeline(session.ID, 1, LifelineHint); rr.Code != http.StatusConflict {
+		t.Errorf("exhausted hint lifeline returned %d, want %d", rr.Code, http.StatusConflict)
+	}
+}
+
+func TestScoringRulesPoints(t *testing.T) {
+	rules := ScoringRules{Correct: 4, Incorrect: -1, FiftyFiftyPenalty: 2, HintPenalty: 1}
+
+	if got := rules.points(true, "", nil); got != 4 {
+		t.Errorf("points(correct) = %d, want 4", got)
+	}
+	if got := rules.points(false, "", []Lifeline{LifelineHint}); got != -1 {
+		t.Errorf("points(incorrect) = %d, want -1", got)
+	}
+	if got := rules.points(true, "", []Lifeline{LifelineFiftyFifty, LifelineHint}); got != 1 {
+		t.Errorf("points(correct, both lifelines) = %d, want 1", got)
+	}
+
+	rules.FiftyFiftyPenalty = 10
+	if got := rules.points(true, "", []Lifeline{LifelineFiftyFifty}); got != -1 {
+		t.Errorf("penalty should not take a correct answer below incorrect points, got %d", got)
+	}
+}
+
+func TestFiftyFiftyRemovalsTwoChoices(t *testing.T) {
+	q := Question{ID: 1, Question: "Q?", Choices: []string{"Yes", "No"}, AnswerIndex: 0}
+	if removed := fiftyFiftyRemovals(q); removed != nil {
+		t.Errorf("fifty-fifty on a two-choice question should be unavailable, got %v", removed)
+	}
+}
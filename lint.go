@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// lintSeverity says whether a lint issue stops a question bank from loading
+type lintSeverity string
+
+const (
+	// severityError issues make loadQuestions fail
+	severityError lintSeverity = "error"
+	// severityWarning issues are logged at load but do not stop it
+	severityWarning lintSeverity = "warning"
+)
+
+// Length limits beyond which text is reported as too long to read
+// comfortably on the quiz page
+const (
+	maxQuestionLength    = 500
+	maxChoiceLength      = 200
+	maxExplanationLength = 1000
+)
+
+// lintIssue is one problem found in a question bank. Index is the question's
+// position in the file, or -1 for problems with the whole file.
+type lintIssue struct {
+	File     string       `json:"file,omitempty"`
+	Severity lintSeverity `json:"severity"`
+	Rule     string       `json:"rule"`
+	Index    int          `json:"index"`
+	ID       int          `json:"id,omitempty"`
+	Message  string       `json:"message"`
+}
+
+func (i lintIssue) String() string {
+	if i.Index < 0 {
+		return fmt.Sprintf("%s: %s [%s]", i.Severity, i.Message, i.Rule)
+	}
+	return fmt.Sprintf("%s: question %d (id=%d): %s [%s]", i.Severity, i.Index, i.ID, i.Message, i.Rule)
+}
+
+// lintQuestion checks a single question on its own. The returned issues do
+// not have Index or ID set.
+func lintQuestion(q Question) []lintIssue {
+	if q.Template != nil {
+		return lintTemplate(q)
+	}
+
+	var issues []lintIssue
+	add := func(severity lintSeverity, rule, format string, args ...interface{}) {
+		issues = append(issues, lintIssue{Severity: severity, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if strings.TrimSpace(q.Question) == "" {
+		add(severityError, "empty-question", "question text is empty")
+	} else if n := utf8.RuneCountInString(q.Question); n > maxQuestionLength {
+		add(severityWarning, "long-text", "question text is %d characters (limit %d)", n, maxQuestionLength)
+	}
+
+	if len(q.Choices) < 2 {
+		add(severityError, "too-few-choices", "has %d choices, need at least 2", len(q.Choices))
+	}
+	seen := make(map[string]int)
+	for i, c := range q.Choices {
+		key := strings.ToLower(strings.Join(strings.Fields(c), " "))
+		switch prev, dup := seen[key]; {
+		case key == "":
+			add(severityError, "empty-choice", "choice %d is empty", i)
+		case dup:
+			add(severityError, "duplicate-choice", "choice %d %q repeats choice %d", i, c, prev)
+		default:
+			seen[key] = i
+		}
+		if n := utf8.RuneCountInString(c); n > maxChoiceLength {
+			add(severityWarning, "long-text", "choice %d is %d characters (limit %d)", i, n, maxChoiceLength)
+		}
+	}
+
+	if err := q.validate(); err != nil {
+		add(severityError, "answer-index", "%v", err)
+	}
+	if q.Version < 0 {
+		add(severityError, "version", "version %d must be positive", q.Version)
+	}
+
+	if strings.TrimSpace(q.Explanation) == "" {
+		add(severityWarning, "missing-explanation", "has no explanation")
+	} else if n := utf8.RuneCountInString(q.Explanation); n > maxExplanationLength {
+		add(severityWarning, "long-text", "explanation is %d characters (limit %d)", n, maxExplanationLength)
+	}
+	return issues
+}
+
+// templateLintSamples is how many variants of a template question are
+// generated when it is checked
+const templateLintSamples = 20
+
+// lintTemplate checks a template question's variables and expressions, that
+// variants can be generated from a range of seeds, and the first variant's
+// text and choices like any other question
+func lintTemplate(q Question) []lintIssue {
+	templateIssue := func(format string, args ...interface{}) []lintIssue {
+		return []lintIssue{{Severity: severityError, Rule: "template", Message: fmt.Sprintf(format, args...)}}
+	}
+	if err := q.Template.validate(q); err != nil {
+		return templateIssue("%v", err)
+	}
+
+	var first Question
+	for seed := int64(0); seed < templateLintSamples; seed++ {
+		variant, err := generateVariant(q, seed)
+		if err != nil {
+			return templateIssue("seed %d: %v", seed, err)
+		}
+		if seed == 0 {
+			first = variant
+		}
+	}
+	return lintQuestion(first)
+}
+
+// lintQuestions checks every question in a bank, including that IDs are
+// unique
+func lintQuestions(questions []Question) []lintIssue {
+	var issues []lintIssue
+	firstIndex := make(map[int]int)
+	for i, q := range questions {
+		for _, issue := range lintQuestion(q) {
+			issue.Index, issue.ID = i, q.ID
+			issues = append(issues, issue)
+		}
+		if prev, dup := firstIndex[q.ID]; dup {
+			issues = append(issues, lintIssue{Severity: severityError, Rule: "duplicate-id", Index: i, ID: q.ID,
+				Message: fmt.Sprintf("id %d is already used by question %d", q.ID, prev)})
+			continue
+		}
+		firstIndex[q.ID] = i
+	}
+	return issues
+}
+
+// lintQuestionBank decodes a question bank and runs every check on it. Issues
+// are ordered with problems with the whole file first, then by question.
+func lintQuestionBank(data []byte) ([]Question, []lintIssue, error) {
+	questions, _, issues, err := decodeQuestionBank(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	issues = append(issues, lintQuestions(questions)...)
+	sort.SliceStable(issues, func(a, b int) bool { return issues[a].Index < issues[b].Index })
+	return questions, issues, nil
+}
+
+// countSeverity returns how many issues have the given severity
+func countSeverity(issues []lintIssue, severity lintSeverity) int {
+	n := 0
+	for _, i := range issues {
+		if i.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// lintError summarizes the errors that stop a question bank from loading
+func lintError(issues []lintIssue) error {
+	var first *lintIssue
+	for i := range issues {
+		if issues[i].Severity == severityError {
+			first = &issues[i]
+			break
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	msg := strings.TrimPrefix(first.String(), "error: ")
+	if n := countSeverity(issues, severityError); n > 1 {
+		msg += fmt.Sprintf(" (and %d more errors; run the lint command for details)", n-1)
+	}
+	return errors.New(msg)
+}
+
+// lintWarned remembers the banks whose warnings were logged, so reloading
+// questions for every quiz does not repeat them
+var lintWarned sync.Map
+
+// logLintWarnings logs the warnings for a question bank once per file
+func logLintWarnings(path string, issues []lintIssue) {
+	if countSeverity(issues, severityWarning) == 0 {
+		return
+	}
+	if _, done := lintWarned.LoadOrStore(path, true); done {
+		return
+	}
+	for _, i := range issues {
+		if i.Severity == severityWarning {
+			log.Printf("Warning: %s: %s", path, strings.TrimPrefix(i.String(), "warning: "))
+		}
+	}
+}
+
+// lintFile runs every check on the question bank file or directory at path.
+// A file that cannot be read or parsed is reported as a single error.
+func lintFile(path string) []lintIssue {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		_, issues, err := readQuestionDir(path)
+		if err != nil {
+			issues = []lintIssue{syntaxIssue(err)}
+		}
+		for i := range issues {
+			if issues[i].File == "" {
+				issues[i].File = path
+			}
+		}
+		return issues
+	}
+
+	var issues []lintIssue
+	data, err := os.ReadFile(path)
+	if err == nil {
+		_, issues, err = lintQuestionBank(data)
+	}
+	if err != nil {
+		issues = []lintIssue{syntaxIssue(err)}
+	}
+	for i := range issues {
+		issues[i].File = path
+	}
+	return issues
+}
+
+// runLint implements the lint command, which reports problems in question
+// banks and exits non-zero when any has errors, or warnings with -strict
+func runLint(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	asJSON := fs.Bool("json", false, "print the issues as a JSON array")
+	strict := fs.Bool("strict", false, "exit non-zero on warnings as well as errors")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{questionBankPath()}
+	}
+
+	issues := []lintIssue{}
+	for _, path := range paths {
+		issues = append(issues, lintFile(path)...)
+	}
+	errorCount, warningCount := countSeverity(issues, severityError), countSeverity(issues, severityWarning)
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(issues); err != nil {
+			fmt.Fprintf(stderr, "lint: %v\n", err)
+			return 1
+		}
+	} else {
+		for _, i := range issues {
+			fmt.Fprintf(stdout, "%s: %s\n", i.File, i)
+		}
+		fmt.Fprintf(stdout, "%d errors, %d warnings\n", errorCount, warningCount)
+	}
+
+	if errorCount > 0 || (*strict && warningCount > 0) {
+		return 1
+	}
+	return 0
+}
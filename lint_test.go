@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintQuestions(t *testing.T) {
+	questions := []Question{
+		{ID: 1, Question: "Fine?", Choices: []string{"Yes", "No"}, Explanation: "It is."},
+		{ID: 2, Question: " ", Choices: []string{"Only"}, Explanation: "x"},
+		{ID: 1, Question: "Repeats?", Choices: []string{"Paris", " paris ", ""}, AnswerIndex: 3},
+		{ID: 4, Question: strings.Repeat("x", maxQuestionLength+1), Choices: []string{"A", strings.Repeat("b", maxChoiceLength+1)}},
+	}
+	var got []string
+	for _, i := range lintQuestions(questions) {
+		got = append(got, i.String())
+	}
+	want := []string{
+		"error: question 1 (id=2): question text is empty [empty-question]",
+		"error: question 1 (id=2): has 1 choices, need at least 2 [too-few-choices]",
+		`error: question 2 (id=1): choice 1 " paris " repeats choice 0 [duplicate-choice]`,
+		"error: question 2 (id=1): choice 2 is empty [empty-choice]",
+		"error: question 2 (id=1): correct index 3 is out of bounds for answers array of length 3 [answer-index]",
+		"warning: question 2 (id=1): has no explanation [missing-explanation]",
+		"error: question 2 (id=1): id 1 is already used by question 0 [duplicate-id]",
+		"warning: question 3 (id=4): question text is 501 characters (limit 500) [long-text]",
+		"warning: question 3 (id=4): choice 1 is 201 characters (limit 200) [long-text]",
+		"warning: question 3 (id=4): has no explanation [missing-explanation]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("lintQuestions():\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLintQuestionBankUnknownFields(t *testing.T) {
+	data := `{"schema_version": 2, "questions": [
+		{"id": 1, "question": "Q?", "choices": ["A", "B"], "answer_index": 0, "explaination": "typo", "notes": "x"}
+	]}`
+	_, issues, err := lintQuestionBank([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rules []string
+	for _, i := range issues {
+		rules = append(rules, i.Rule+" "+i.Message)
+	}
+	want := []string{
+		`unknown-field unknown field "explaination" is ignored`,
+		`unknown-field unknown field "notes" is ignored`,
+		"missing-explanation has no explanation",
+	}
+	if strings.Join(rules, "\n") != strings.Join(want, "\n") {
+		t.Errorf("issues:\n%s\nwant:\n%s", strings.Join(rules, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLoadQuestionsLintErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "questions.json")
+	data := `{"schema_version": 2, "questions": [
+		{"id": 1, "question": "Q?", "choices": ["A", "B"], "answer_index": 0, "explanation": "x"},
+		{"id": 1, "question": "Q2?", "choices": ["A", "A"], "answer_index": 0, "explanation": "x"}
+	]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := loadQuestionsFrom(path)
+	want := `question 1 (id=1): choice 1 "A" repeats choice 0 [duplicate-choice] (and 1 more errors; run the lint command for details)`
+	if err == nil || err.Error() != want {
+		t.Errorf("loadQuestionsFrom() error = %v, want %q", err, want)
+	}
+}
+
+func TestRunLint(t *testing.T) {
+	dir := t.TempDir()
+	clean := filepath.Join(dir, "clean.json")
+	warn := filepath.Join(dir, "warn.json")
+	broken := filepath.Join(dir, "broken.json")
+	files := map[string]string{
+		clean:  `{"schema_version": 2, "questions": [{"id": 1, "question": "Q?", "choices": ["A", "B"], "answer_index": 1, "explanation": "B"}]}`,
+		warn:   `[{"id": 1, "question": "Q?", "choices": ["A", "B"], "answer_index": 1, "explanation": "B"}]`,
+		broken: `{"schema_version": 2, "questions": [`,
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runCommand([]string{"lint", clean}, &stdout, &stderr); code != 0 || stdout.String() != "0 errors, 0 warnings\n" {
+		t.Errorf("clean bank exited %d: %q", code, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := runCommand([]string{"lint", warn}, &stdout, &stderr); code != 0 {
+		t.Errorf("warnings exited %d, want 0", code)
+	}
+	if !strings.Contains(stdout.String(), warn+": warning: schema version 1 is deprecated") {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+	if code := runCommand([]string{"lint", "-strict", warn}, &stdout, &stderr); code != 1 {
+		t.Errorf("-strict with warnings exited %d, want 1", code)
+	}
+
+	stdout.Reset()
+	if code := runCommand([]string{"lint", "-json", clean, warn, broken}, &stdout, &stderr); code != 1 {
+		t.Errorf("broken bank exited %d, want 1", code)
+	}
+	var issues []lintIssue
+	if err := json.Unmarshal(stdout.Bytes(), &issues); err != nil {
+		t.Fatalf("-json output is not a JSON array: %v\n%s", err, stdout.String())
+	}
+	last := issues[len(issues)-1]
+	if last.File != broken || last.Severity != severityError || last.Rule != "syntax" || last.Index != -1 {
+		t.Errorf("last issue = %+v, want a syntax error in %s", last, broken)
+	}
+
+	stdout.Reset()
+	runCommand([]string{"lint", "-json", clean}, &stdout, &stderr)
+	if strings.TrimSpace(stdout.String()) != "[]" {
+		t.Errorf("-json with no issues = %q, want []", stdout.String())
+	}
+}
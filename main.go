@@ -4,13 +4,14 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"math/rand"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -28,15 +29,208 @@ type Question struct {
 	Choices     []string `json:"choices"`
 	AnswerIndex int      `json:"answer_index"`
 	Explanation string   `json:"explanation"`
+	Hint        string   `json:"hint,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+
+	// Namespace is the namespace of the file a question from a directory
+	// bank was loaded from, and LocalID its ID in that file
+	Namespace string `json:"-"`
+	LocalID   int    `json:"-"`
+
+	// Difficulty and Discrimination are item response theory parameters
+	// used by adaptive quizzes; an unset discrimination is treated as 1
+	Difficulty     float64 `json:"difficulty,omitempty"`
+	Discrimination float64 `json:"discrimination,omitempty"`
+
+	// Version is bumped whenever the question is corrected, so answers given
+	// to an earlier version can be regraded; unset means version 1
+	Version int `json:"version,omitempty"`
+
+	// Template makes the question a generator of variants; VariantSeed is
+	// the seed a served variant was generated from
+	Template    *QuestionTemplate `json:"template,omitempty"`
+	VariantSeed int64             `json:"-"`
+
+	// Retired questions stay in the bank for answer history and regrading
+	// but are no longer served
+	Retired bool `json:"retired,omitempty"`
+	// Author credits the player who submitted the question, and Submission
+	// is the ID of the submission it was approved from
+	Author     string `json:"author,omitempty"`
+	Submission string `json:"submission,omitempty"`
+}
+
+// Answer records the choice a player submitted for a single question.
+// Choice, Correct, Confidence and Points reflect the first attempt, which is
+// what gets scored; Attempts counts practice-mode retries.
+type Answer struct {
+	QuestionID int
+	Choice     int
+	Correct    bool
+	Confidence Confidence
+	Points     int
+	Lifelines  []Lifeline
+	Attempts   int
+	// Skipped marks a question whose section ran out of time before it was
+	// answered
+	Skipped bool
+	// QuestionVersion is the version of the question that was served
+	QuestionVersion int
+}
+
+// AnswerFeedback tells a practice-mode player how their last answer went
+type AnswerFeedback struct {
+	Question    string
+	Correct     bool
+	Explanation string
 }
 
 // QuizSession represents an active quiz session
 type QuizSession struct {
 	ID        string
+	PlayerID  string
+	QuizSlug  string
+	QuizTitle string
+	Mode      QuizMode
 	Questions []Question
+	Answers   []Answer
 	Current   int
 	Score     int
 	StartTime time.Time
+	EndTime   time.Time
+	// QuestionStart is when the current question was first served, used to
+	// measure answer latency
+	QuestionStart time.Time
+	// Deadline is when a timed quiz ends; TimedOut is set once an action
+	// arrives after it, finishing the session with questions unanswered
+	Deadline time.Time
+	TimedOut bool
+
+	Scoring        ScoringRules
+	LifelineLimits LifelineLimits
+	Lifelines      []LifelineUse
+
+	// Branching quizzes grow Questions as the player answers; Path holds the
+	// graph node name for each question served so far
+	Branching       *BranchGraph
+	Path            []string
+	branchQuestions map[string]Question
+
+	// Adaptive quizzes also grow Questions, picking each one from the pool
+	// to best measure the player's current Ability estimate
+	Adaptive     *AdaptiveSettings
+	Ability      float64
+	AbilitySE    float64
+	adaptivePool []Question
+
+	// Study sessions update the player's spaced repetition schedule
+	Study bool
+
+	// Sectioned quizzes show each section's instructions before its
+	// questions; SectionDeadline is set while a timed section is running
+	Sections        []SessionSection
+	SectionIndex    int
+	SectionStarted  bool
+	SectionDeadline time.Time
+
+	// Attempt numbers the session among the player's attempts at a quiz
+	// with an attempt limit; it is 0 for unlimited quizzes
+	Attempt         int
+	MaxAttempts     int
+	KeepScore       ScorePolicy
+	attemptRecorded bool
+
+	// ResumeCode lets the player pick the session up again on another device
+	ResumeCode string
+
+	// Pauses records every time the clock was stopped, up to MaxPause in
+	// total
+	Pauses   []PauseInterval
+	MaxPause time.Duration
+
+	// Seed picks the variant of each template question the session serves
+	Seed int64
+}
+
+// MaxScore returns the highest score achievable in the session
+func (s *QuizSession) MaxScore() int {
+	return len(s.Questions) * s.Scoring.maxPoints()
+}
+
+// ChoiceOption is a single choice as shown on the question page
+type ChoiceOption struct {
+	Index   int
+	Text    string
+	Removed bool
+}
+
+// Finished reports whether every question in the session has been answered
+// or its time limit has run out
+func (s *QuizSession) Finished() bool {
+	return s.TimedOut || s.Current >= len(s.Questions)
+}
+
+// checkDeadline finishes a timed session whose deadline has passed, or
+// skips the rest of a timed section, and reports whether it did. Deadlines
+// cannot pass while the session is paused. Callers must hold sessionMux if
+// the session is shared.
+func (s *QuizSession) checkDeadline(now time.Time) bool {
+	if s.paused(now) {
+		return false
+	}
+	if s.Deadline.IsZero() || now.Before(s.Deadline) || s.Finished() {
+		return s.checkSectionDeadline(now)
+	}
+	s.TimedOut = true
+	s.EndTime = s.Deadline
+	return true
+}
+
+// questionPage is the data passed to quiz.html when rendering a question.
+// Score and Feedback are only populated in practice mode, and Hint only once
+// the hint lifeline has been used on the question.
+type questionPage struct {
+	Question       Question
+	QuestionNumber int
+	TotalQuestions int
+	Score          int
+	SessionID      string
+	QuestionIndex  int
+	HMACSignature  string
+	QuizTitle      string
+	ResumeCode     string
+	SectionTitle   string
+	SectionNumber  int
+	TotalSections  int
+	Mode           QuizMode
+	ShowFeedback   bool
+	Feedback       *AnswerFeedback
+
+	// SecondsRemaining counts down to the deadline of a timed quiz or
+	// section, whichever is sooner
+	Timed            bool
+	SecondsRemaining int
+
+	// CanPause is set while the player has pause time left to stop the clock
+	CanPause              bool
+	PauseSecondsRemaining int
+
+	// AskConfidence is set when answers are scored with confidence marking
+	AskConfidence bool
+	// Branching is set when the total number of questions depends on the
+	// answers given, as in branching and adaptive quizzes, so TotalQuestions
+	// only counts those served so far
+	Branching bool
+
+	Choices             []ChoiceOption
+	Hint                string
+	HasHint             bool
+	FiftyFiftyRemaining int
+	HintsRemaining      int
+
+	// Preview is set when an admin is previewing a question, so the page's
+	// forms lead nowhere
+	Preview bool
 }
 
 var (
@@ -57,10 +251,19 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	quizzes, err := loadQuizDefinitions()
+	if err != nil {
+		log.Printf("Error loading quiz definitions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
 	data := struct {
 		Message string
+		Quizzes []QuizDefinition
 	}{
 		Message: "Welcome to the Quiz Application!",
+		Quizzes: quizzes,
 	}
 
 	if err := tmpl.Execute(w, data); err != nil {
@@ -80,28 +283,50 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
-// loadQuestions reads and parses the questions.json file
+// loadQuestions loads the question bank from the server's question source:
+// questions.json unless QUESTIONS_PATH names another file, a directory of
+// bank files or a URL. New and changed questions are added to the edit
+// history.
 func loadQuestions() ([]Question, error) {
-	data, err := os.ReadFile("questions.json")
+	questions, err := defaultQuestionSource().Load()
 	if err != nil {
 		return nil, err
 	}
-
-	var questions []Question
-	if err := json.Unmarshal(data, &questions); err != nil {
-		return nil, err
+	if err := recordQuestionVersions(questions, time.Now()); err != nil {
+		log.Printf("Error recording question versions: %v", err)
 	}
+	return questions, nil
+}
 
+// loadQuestionsFrom loads the question bank file, directory or URL at
+// location
+func loadQuestionsFrom(location string) ([]Question, error) {
+	return questionSourceFor(location).Load()
+}
 
-	// Validate that correct index is within bounds of answers array
-	for i, q := range questions {
-		if q.AnswerIndex < 0 || q.AnswerIndex >= len(q.Choices) {
-			return nil, fmt.Errorf("question %d (id=%d): correct index %d is out of bounds for answers array of length %d", i, q.ID, q.AnswerIndex, len(q.Choices))
-		}
+// loadQuestionData parses a question bank read from name, failing on any
+// lint error and logging lint warnings
+func loadQuestionData(name string, data []byte) ([]Question, error) {
+	questions, issues, err := lintQuestionBank(data)
+	if err != nil {
+		return nil, err
 	}
+	if err := lintError(issues); err != nil {
+		return nil, err
+	}
+	logLintWarnings(name, issues)
 	return questions, nil
 }
 
+// validate checks that a question's correct index points at one of its
+// choices
+func (q Question) validate() error {
+	if q.AnswerIndex < 0 || q.AnswerIndex >= len(q.Choices) {
+		return fmt.Errorf("correct index %d is out of bounds for answers array of length %d", q.AnswerIndex, len(q.Choices))
+	}
+	return nil
+}
+
 // selectRandomQuestions randomly selects n questions from the provided slice
 func selectRandomQuestions(questions []Question, n int) []Question {
 	if len(questions) <= n {
@@ -122,14 +347,62 @@ func selectRandomQuestions(questions []Question, n int) []Question {
 	return shuffled[:n]
 }
 
-// quizHandler handles the GET /quiz endpoint to start a new quiz session
-// quizHandler handles the GET /quiz endpoint to start a new quiz session
+// quizHandler handles the GET /quiz endpoint to start a new session of the
+// first quiz definition, and GET /quiz/{slug} to start the named one
 func quizHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	defs, err := loadQuizDefinitions()
+	if err != nil {
+		log.Printf("Error loading quiz definition: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	def := defs[0]
+	if slug := strings.Trim(strings.TrimPrefix(r.URL.Path, "/quiz"), "/"); slug != "" {
+		var ok bool
+		if def, ok = findQuizDefinition(defs, slug); !ok {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	startQuiz(w, r, def)
+}
+
+// startQuiz creates a new session for the given quiz definition and renders
+// its first question
+func startQuiz(w http.ResponseWriter, r *http.Request, def QuizDefinition) {
+	player := playerID(w, r)
+	now := time.Now()
+
+	// A player returning to a quiz they left unfinished chooses whether to
+	// pick it up again or start over. Starting over only drops the unfinished
+	// session once the new one exists, so a player who is refused a new
+	// attempt keeps the old one.
+	var replaced *QuizSession
+	if existing := inProgressSession(player, def.Slug); existing != nil {
+		switch r.URL.Query().Get("action") {
+		case "resume":
+			resumeSession(w, r, existing)
+			return
+		case "restart":
+			replaced = existing
+		default:
+			renderResumeOffer(w, newResumeOffer(existing))
+			return
+		}
+	}
+
+	if msg := def.availability(now); msg != "" {
+		renderUnavailable(w, unavailablePage{QuizTitle: def.Title, Message: msg})
+		return
+	}
+
 	// Load all questions
 	allQuestions, err := loadQuestions()
 	if err != nil {
@@ -137,36 +410,213 @@ func quizHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	allQuestions = activeQuestions(allQuestions)
+	pool := def.Filters.apply(allQuestions)
+	if len(pool) == 0 {
+		log.Printf("Error selecting questions: quiz %q matches no questions", def.Slug)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 
-	// Select random questions
-	selectedQuestions := selectRandomQuestions(allQuestions, NumQuestions)
-	
-	// Log selected question IDs for randomization verification
-	questionIDs := make([]int, len(selectedQuestions))
-	for i, q := range selectedQuestions {
-		questionIDs[i] = q.ID
+	// Select random questions, or start at the root of a branching quiz.
+	// Unseen questions are only recorded as seen once an attempt is claimed,
+	// so a player who has run out is not marked as having seen any.
+	picker, err := newUnseenPicker(player)
+	if err != nil {
+		log.Printf("Error selecting questions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	var selectedQuestions []Question
+	var branchQuestions map[string]Question
+	var sections []SessionSection
+	var pickedUnseen bool
+	if len(def.Sections) > 0 {
+		selectedQuestions, sections, err = selectSectionQuestions(picker, allQuestions, pool, def.Sections)
+		if err != nil {
+			log.Printf("Error selecting questions: quiz %q: %v", def.Slug, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		pickedUnseen = true
+	} else if def.Branching != nil {
+		branchQuestions, err = def.Branching.resolve(allQuestions)
+		if err != nil {
+			log.Printf("Error loading quiz definition: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		selectedQuestions = []Question{branchQuestions[def.Branching.Start]}
+	} else if def.Adaptive != nil {
+		first := mostInformative(pool, nil, 0)
+		selectedQuestions = []Question{pool[first]}
+	} else if def.SpacedRepetition {
+		states, err := loadReviewStates(player)
+		if err != nil {
+			log.Printf("Error loading study state: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		selectedQuestions = selectStudyQuestions(pool, states, def.Length, time.Now())
+	} else {
+		selectedQuestions = picker.pick(allQuestions, pool, def.Length)
+		pickedUnseen = true
 	}
-	log.Printf("Quiz started with questions: %v", questionIDs)
 
 	// Create a new session
 	sessionID := generateSessionID()
 	session := &QuizSession{
 		ID:        sessionID,
+		PlayerID:  player,
+		QuizSlug:  def.Slug,
+		QuizTitle: def.Title,
+		Mode:      def.Mode,
 		Questions: selectedQuestions,
 		Current:   0,
 		Score:     0,
-		StartTime: time.Now(),
+		StartTime: now,
+		Seed:      newSessionSeed(),
+
+		ResumeCode:     newResumeCode(),
+		QuestionStart:  now,
+		Scoring:        def.Scoring,
+		LifelineLimits: def.Lifelines,
+	}
+	if session.Questions, err = session.instantiateAll(session.Questions); err != nil {
+		log.Printf("Error selecting questions: quiz %q: %v", def.Slug, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if def.Branching != nil {
+		session.Branching = def.Branching
+		session.Path = []string{def.Branching.Start}
+		session.branchQuestions = branchQuestions
+	}
+	if def.Adaptive != nil {
+		session.Adaptive = def.Adaptive
+		session.AbilitySE = 1
+		session.adaptivePool = pool
+	}
+	session.Study = def.SpacedRepetition
+	session.Sections = sections
+	session.MaxAttempts = def.MaxAttempts
+	session.KeepScore = def.KeepScore
+	session.MaxPause = time.Duration(def.MaxPauseSeconds) * time.Second
+	if def.TimeLimit() > 0 {
+		session.Deadline = now.Add(def.TimeLimit())
 	}
 
+	// Claim an attempt only once the session is ready to start
+	var attempt int
+	if def.MaxAttempts > 0 {
+		attempt, err = startAttempt(player, def.Slug, sessionID, def.MaxAttempts, now)
+		if errors.Is(err, errNoAttemptsLeft) {
+			page, err := attemptsUsedPage(player, def)
+			if err != nil {
+				log.Printf("Error loading attempts: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			renderUnavailable(w, page)
+			return
+		}
+		if err != nil {
+			log.Printf("Error recording attempt: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if pickedUnseen {
+		if err := picker.save(); err != nil {
+			log.Printf("Error recording seen questions: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	session.Attempt = attempt
+
+	// Log selected question IDs for randomization verification
+	questionIDs := make([]int, len(selectedQuestions))
+	for i, q := range selectedQuestions {
+		questionIDs[i] = q.ID
+	}
+	log.Printf("Quiz %q started in %s mode with questions: %v", def.Slug, def.Mode, questionIDs)
+
 	// Store session
 	sessionMux.Lock()
 	sessions[sessionID] = session
+	if replaced != nil {
+		delete(sessions, replaced.ID)
+	}
 	sessionMux.Unlock()
 
-	// Generate HMAC signature for the current state
-	hmacSignature := signState(sessionID, 0)
+	if session.awaitingSection() {
+		renderSection(w, newSectionPage(session))
+		return
+	}
+	renderQuestion(w, newQuestionPage(session))
+}
+
+// newQuestionPage builds the template data for the session's current question.
+// Callers must hold sessionMux if the session is shared.
+func newQuestionPage(session *QuizSession) questionPage {
+	page := questionPage{
+		Question:       session.Questions[session.Current],
+		QuestionNumber: session.Current + 1,
+		TotalQuestions: len(session.Questions),
+		SessionID:      session.ID,
+		QuestionIndex:  session.Current,
+		HMACSignature:  signState(session.ID, session.Current),
+		QuizTitle:      session.QuizTitle,
+		ResumeCode:     session.ResumeCode,
+		Mode:           session.Mode,
+		AskConfidence:  session.Scoring.ConfidenceMarking,
+		Branching:      session.Branching != nil || session.Adaptive != nil,
+
+		FiftyFiftyRemaining: session.lifelinesRemaining(LifelineFiftyFifty),
+		HintsRemaining:      session.lifelinesRemaining(LifelineHint),
+	}
+
+	if deadline := session.nextDeadline(); !deadline.IsZero() {
+		page.Timed = true
+		page.SecondsRemaining = int(time.Until(deadline).Seconds())
+	}
+	if remaining := session.pauseRemaining(); remaining > 0 {
+		page.CanPause = true
+		page.PauseSecondsRemaining = int(remaining.Seconds())
+	}
+	if len(session.Sections) > 0 {
+		page.SectionTitle = session.Sections[session.SectionIndex].Title
+		page.SectionNumber = session.SectionIndex + 1
+		page.TotalSections = len(session.Sections)
+	}
+
+	question := page.Question
+	page.HasHint = question.Hint != ""
+
+	removed := make(map[int]bool)
+	for _, i := range session.removedChoices(session.Current) {
+		removed[i] = true
+	}
+	for i, text := range question.Choices {
+		page.Choices = append(page.Choices, ChoiceOption{Index: i, Text: text, Removed: removed[i]})
+	}
+	for _, used := range session.lifelinesFor(session.Current) {
+		if used == LifelineHint {
+			page.Hint = question.Hint
+		}
+	}
 
-	// Parse and render template
+	// Exam mode keeps the running score hidden until the results page
+	if session.Mode == ModePractice {
+		page.Score = session.Score
+		page.ShowFeedback = true
+	}
+	return page
+}
+
+// renderQuestion renders quiz.html with the given question page data
+func renderQuestion(w http.ResponseWriter, data questionPage) {
 	tmpl, err := template.ParseFiles("quiz.html")
 	if err != nil {
 		log.Printf("Error parsing template: %v", err)
@@ -174,30 +624,13 @@ func quizHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data := struct {
-		Question       Question
-		QuestionNumber int
-		TotalQuestions int
-		Score          int
-		SessionID      string
-		QuestionIndex  int
-		HMACSignature  string
-	}{
-		Question:       selectedQuestions[0],
-		QuestionNumber: 1,
-		TotalQuestions: len(selectedQuestions),
-		Score:          0,
-		SessionID:      sessionID,
-		QuestionIndex:  0,
-		HMACSignature:  hmacSignature,
-	}
-
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := tmpl.Execute(w, data); err != nil {
 		log.Printf("Error executing template: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
 }
+
 // generateSessionID creates a unique session identifier
 func generateSessionID() string {
 	return time.Now().Format("20060102150405") + "-" + randString(8)
@@ -215,19 +648,46 @@ func randString(n int) string {
 }
 
 func main() {
+	// Run a subcommand instead of the server when one is given
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	// Check if required files exist
 	if _, err := os.Stat("home.html"); os.IsNotExist(err) {
 		log.Fatal("home.html not found")
 	}
 
-	if _, err := os.Stat("questions.json"); os.IsNotExist(err) {
-		log.Fatal("questions.json not found")
+	questions, err := loadQuestions()
+	if err != nil {
+		log.Fatalf("Error loading questions: %v", err)
+	}
+	if _, err := checkQuizDefinitions(questions); err != nil {
+		log.Fatalf("Error loading quiz definitions: %v", err)
 	}
 
 	// Register handlers
 	http.HandleFunc("/", homeHandler)
 	http.HandleFunc("/health", healthHandler)
 	http.HandleFunc("/quiz", quizHandler)
+	http.HandleFunc("/quiz/", quizHandler)
+	http.HandleFunc("/answer", answerHandler)
+	http.HandleFunc("/lifeline", lifelineHandler)
+	http.HandleFunc("/section", sectionHandler)
+	http.HandleFunc("/resume", resumeHandler)
+	http.HandleFunc("/pause", pauseHandler)
+	http.HandleFunc("/unpause", pauseHandler)
+	http.HandleFunc("/results", resultsHandler)
+	http.HandleFunc("/admin/report", requireAdmin(adminReportHandler))
+	http.HandleFunc("/admin/versions", requireAdmin(questionVersionsHandler))
+	http.HandleFunc("/admin/regrade", requireAdmin(regradeHandler))
+	http.HandleFunc("/admin/questions", requireAdmin(adminQuestionsHandler))
+	http.HandleFunc("/admin/questions/edit", requireAdmin(adminQuestionEditHandler))
+	http.HandleFunc("/admin/questions/retire", requireAdmin(adminQuestionRetireHandler))
+	http.HandleFunc("/admin/questions/preview", requireAdmin(adminQuestionPreviewHandler))
+	http.HandleFunc("/submit", submitHandler)
+	http.HandleFunc("/admin/submissions", requireAdmin(adminSubmissionsHandler))
+	http.HandleFunc("/admin/submissions/review", requireAdmin(adminSubmissionReviewHandler))
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -253,3 +713,10 @@ func signState(sessionID string, questionIndex int) string {
 	// Return base64-encoded signature
 	return base64.StdEncoding.EncodeToString(h.Sum(nil))
 }
+
+// verifyState reports whether signature is a valid signState signature for the
+// given session and question index
+func verifyState(sessionID string, questionIndex int, signature string) bool {
+	expected := signState(sessionID, questionIndex)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PauseInterval is one period a session's clock was stopped. End is zero
+// while the pause is still in progress.
+type PauseInterval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// pausedAt returns when the current pause began, or the zero time if the
+// session is not paused
+func (s *QuizSession) pausedAt() time.Time {
+	if n := len(s.Pauses); n > 0 && s.Pauses[n-1].End.IsZero() {
+		return s.Pauses[n-1].Start
+	}
+	return time.Time{}
+}
+
+// pausedTime returns the total length of the session's finished pauses
+func (s *QuizSession) pausedTime() time.Duration {
+	var total time.Duration
+	for _, p := range s.Pauses {
+		if !p.End.IsZero() {
+			total += p.End.Sub(p.Start)
+		}
+	}
+	return total
+}
+
+// pauseRemaining returns how much pause time the session has left
+func (s *QuizSession) pauseRemaining() time.Duration {
+	return max(s.MaxPause-s.pausedTime(), 0)
+}
+
+// pause stops the session's clock
+func (s *QuizSession) pause(now time.Time) {
+	s.Pauses = append(s.Pauses, PauseInterval{Start: now})
+}
+
+// unpause restarts the clock, pushing the quiz and section deadlines and the
+// question start back by the length of the pause so none of it counts. A
+// pause is never credited beyond the remaining allowance.
+func (s *QuizSession) unpause(now time.Time) {
+	start := s.pausedAt()
+	if start.IsZero() {
+		return
+	}
+	end := now
+	if limit := start.Add(s.pauseRemaining()); end.After(limit) {
+		end = limit
+	}
+
+	paused := end.Sub(start)
+	s.Pauses[len(s.Pauses)-1].End = end
+	if !s.Deadline.IsZero() {
+		s.Deadline = s.Deadline.Add(paused)
+	}
+	if !s.SectionDeadline.IsZero() {
+		s.SectionDeadline = s.SectionDeadline.Add(paused)
+	}
+	s.QuestionStart = s.QuestionStart.Add(paused)
+}
+
+// paused reports whether the session's clock is stopped at now. A pause that
+// has used up the allowance ends by itself, with the clock restarting when
+// the allowance ran out.
+func (s *QuizSession) paused(now time.Time) bool {
+	start := s.pausedAt()
+	if start.IsZero() {
+		return false
+	}
+	if now.Before(start.Add(s.pauseRemaining())) {
+		return true
+	}
+	s.unpause(now)
+	return false
+}
+
+// Duration returns how long the player spent on a finished session,
+// excluding time paused
+func (s *QuizSession) Duration() time.Duration {
+	return s.EndTime.Sub(s.StartTime) - s.pausedTime()
+}
+
+// pausePage is the template data for the page shown while a quiz is paused
+type pausePage struct {
+	QuizTitle     string
+	ResumeCode    string
+	SessionID     string
+	QuestionIndex int
+	HMACSignature string
+	// SecondsRemaining is the quiz or section time left when the clock
+	// restarts
+	Timed                 bool
+	SecondsRemaining      int
+	PauseSecondsRemaining int
+}
+
+// newPausePage builds the paused page for a session whose clock is stopped
+func newPausePage(session *QuizSession, now time.Time) pausePage {
+	start := session.pausedAt()
+	page := pausePage{
+		QuizTitle:             session.QuizTitle,
+		ResumeCode:            session.ResumeCode,
+		SessionID:             session.ID,
+		QuestionIndex:         session.Current,
+		HMACSignature:         signState(session.ID, session.Current),
+		PauseSecondsRemaining: int((session.pauseRemaining() - now.Sub(start)).Seconds()),
+	}
+	if deadline := session.nextDeadline(); !deadline.IsZero() {
+		page.Timed = true
+		page.SecondsRemaining = int(deadline.Sub(start).Seconds())
+	}
+	return page
+}
+
+// renderPause executes the paused quiz template
+func renderPause(w http.ResponseWriter, data pausePage) {
+	tmpl, err := template.ParseFiles("pause.html")
+	if err != nil {
+		log.Printf("Error parsing template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// pauseHandler handles POST /pause and POST /unpause, which stop and restart
+// the clock of a session whose quiz allows pausing
+func pauseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	sessionID := r.FormValue("sessionID")
+	questionIndex, err := strconv.Atoi(r.FormValue("questionIndex"))
+	if err != nil {
+		http.Error(w, "Invalid question index", http.StatusBadRequest)
+		return
+	}
+
+	if !verifyState(sessionID, questionIndex, r.FormValue("hmacSignature")) {
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	}
+
+	sessionMux.Lock()
+	session, ok := sessions[sessionID]
+	if !ok {
+		sessionMux.Unlock()
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	session.checkDeadline(now)
+	if session.Finished() {
+		attempt := session.completedAttempt()
+		sessionMux.Unlock()
+		recordAttempt(session, attempt)
+		http.Redirect(w, r, resultsURL(sessionID), http.StatusSeeOther)
+		return
+	}
+
+	if questionIndex != session.Current {
+		sessionMux.Unlock()
+		http.Error(w, "Question already answered", http.StatusConflict)
+		return
+	}
+
+	if r.URL.Path == "/unpause" {
+		if !session.paused(now) {
+			sessionMux.Unlock()
+			http.Error(w, "Quiz is not paused", http.StatusConflict)
+			return
+		}
+		session.unpause(now)
+		sessionMux.Unlock()
+		resumeSession(w, r, session)
+		return
+	}
+
+	if session.paused(now) {
+		sessionMux.Unlock()
+		http.Error(w, "Quiz is already paused", http.StatusConflict)
+		return
+	}
+	if session.pauseRemaining() <= 0 {
+		sessionMux.Unlock()
+		http.Error(w, "No pause time remaining", http.StatusConflict)
+		return
+	}
+
+	session.pause(now)
+	data := newPausePage(session, now)
+	sessionMux.Unlock()
+
+	renderPause(w, data)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postPause sends the session's signed state to /pause or /unpause
+func postPause(path, sessionID string, questionIndex int) *httptest.ResponseRecorder {
+	form := url.Values{}
+	form.Set("sessionID", sessionID)
+	form.Set("questionIndex", fmt.Sprint(questionIndex))
+	form.Set("hmacSignature", signState(sessionID, questionIndex))
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	pauseHandler(rr, req)
+	return rr
+}
+
+func TestPauseAllowance(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	s := &QuizSession{
+		Questions:     []Question{{ID: 1}},
+		StartTime:     t0,
+		QuestionStart: t0,
+		Deadline:      t0.Add(time.Minute),
+		MaxPause:      30 * time.Second,
+	}
+
+	s.Deadline = t0.Add(5 * time.Second)
+	s.pause(t0)
+	if !s.paused(t0.Add(10*time.Second)) || s.checkDeadline(t0.Add(20*time.Second)) {
+		t.Fatal("deadline should not pass while paused within the allowance")
+	}
+
+	s.Pauses, s.Deadline = nil, t0.Add(time.Minute)
+	s.pause(t0)
+	s.unpause(t0.Add(10 * time.Second))
+	if want := t0.Add(70 * time.Second); !s.Deadline.Equal(want) {
+		t.Errorf("deadline after a 10s pause = %v, want %v", s.Deadline, want)
+	}
+	if s.pauseRemaining() != 20*time.Second {
+		t.Errorf("pause remaining = %v, want 20s", s.pauseRemaining())
+	}
+
+	// Staying away past the allowance only credits what was left
+	s.pause(t0.Add(20 * time.Second))
+	if s.paused(t0.Add(5 * time.Minute)) {
+		t.Fatal("pause should end once the allowance is used up")
+	}
+	if s.pausedTime() != 30*time.Second || !s.Deadline.Equal(t0.Add(90*time.Second)) {
+		t.Errorf("paused %v with deadline %v, want 30s and %v", s.pausedTime(), s.Deadline, t0.Add(90*time.Second))
+	}
+
+	s.EndTime = t0.Add(80 * time.Second)
+	if s.Duration() != 50*time.Second {
+		t.Errorf("Duration() = %v, want 50s excluding pauses", s.Duration())
+	}
+}
+
+func TestPauseHandler(t *testing.T) {
+	templates := map[string]string{
+		"quiz.html":  `{{.Question.Question}}{{if .CanPause}} pausable{{end}}`,
+		"pause.html": `paused {{.PauseSecondsRemaining}}`,
+	}
+	for name, body := range templates {
+		if err := os.WriteFile(name, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(name)
+	}
+
+	session := newTestSession(t, []Question{
+		{ID: 1, Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+		{ID: 2, Question: "Q2?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+	})
+	session.Deadline = time.Now().Add(time.Minute)
+
+	if rr := postPause("/pause", session.ID, 0); rr.Code != http.StatusConflict {
+		t.Errorf("pausing without an allowance status = %d, want %d", rr.Code, http.StatusConflict)
+	}
+
+	session.MaxPause = time.Minute
+	if rr := postPause("/pause", session.ID, 0); !strings.HasPrefix(rr.Body.String(), "paused 59") && rr.Body.String() != "paused 60" {
+		t.Fatalf("pause should show the remaining allowance, got %d %q", rr.Code, rr.Body.String())
+	}
+	if rr := postPause("/pause", session.ID, 0); rr.Code != http.StatusConflict {
+		t.Errorf("pausing twice status = %d, want %d", rr.Code, http.StatusConflict)
+	}
+	if rr := postAnswer(session.ID, 0, 0); rr.Code != http.StatusConflict {
+		t.Errorf("answering while paused status = %d, want %d", rr.Code, http.StatusConflict)
+	}
+
+	deadline := session.Deadline
+	session.Pauses[0].Start = session.Pauses[0].Start.Add(-5 * time.Second)
+	rr := postPause("/unpause", session.ID, 0)
+	if body := rr.Body.String(); body != "Q1? pausable" {
+		t.Errorf("unpause should return to the question, got %q", body)
+	}
+	if extended := session.Deadline.Sub(deadline); extended < 5*time.Second {
+		t.Errorf("deadline should move back by the pause, moved %v", extended)
+	}
+	if rr := postPause("/unpause", session.ID, 0); rr.Code != http.StatusConflict {
+		t.Errorf("unpausing a running quiz status = %d, want %d", rr.Code, http.StatusConflict)
+	}
+}
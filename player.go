@@ -0,0 +1,50 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// playerCookieName is the cookie identifying a returning player across
+// sessions
+const playerCookieName = "quiz_player"
+
+// playerCookieMaxAge keeps the player cookie for a year
+const playerCookieMaxAge = 365 * 24 * 60 * 60
+
+// playerID returns the player identifier from the request's cookie, issuing
+// a new one on the response if the player has none yet
+func playerID(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(playerCookieName); err == nil && c.Value != "" {
+		return c.Value
+	}
+
+	id := newPlayerID()
+	setPlayerCookie(w, r, id)
+	return id
+}
+
+// setPlayerCookie identifies the browser as the given player, marking the
+// cookie Secure when the request arrived over TLS
+func setPlayerCookie(w http.ResponseWriter, r *http.Request, id string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     playerCookieName,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   playerCookieMaxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// newPlayerID generates an unguessable player identifier
+func newPlayerID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// Fall back to the session ID generator rather than failing the request
+		return generateSessionID()
+	}
+	return hex.EncodeToString(b)
+}
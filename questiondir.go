@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// questionBankPath returns the location of the question bank the server
+// loads: the file, directory or URL named by QUESTIONS_PATH, or
+// questions.json
+func questionBankPath() string {
+	if path := os.Getenv("QUESTIONS_PATH"); path != "" {
+		return path
+	}
+	return "questions.json"
+}
+
+// namespacedID derives the ID a question from a directory bank is loaded
+// with, so authors of different files can number their questions
+// independently. The ID stays the same as long as neither the namespace nor
+// the question's ID in its file changes, which keeps answer history and study
+// state attached to it.
+func namespacedID(namespace string, id int) int {
+	return stableQuestionID(namespace + ":" + strconv.Itoa(id))
+}
+
+// QuestionRef refers to a question from a quiz definition. It is written as
+// the question's ID or, for a directory bank, as "namespace:id" with the ID
+// the question has in its file, which is translated to the namespaced ID.
+type QuestionRef int
+
+// UnmarshalJSON accepts a number or a string holding an ID or "namespace:id"
+func (r *QuestionRef) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		var id int
+		if err := json.Unmarshal(data, &id); err != nil {
+			return fmt.Errorf("question %s must be an ID or \"namespace:id\"", data)
+		}
+		*r = QuestionRef(id)
+		return nil
+	}
+	id, err := parseQuestionRef(text)
+	if err != nil {
+		return err
+	}
+	*r = QuestionRef(id)
+	return nil
+}
+
+// parseQuestionRef returns the ID of the question written as an ID or as
+// "namespace:id"
+func parseQuestionRef(text string) (int, error) {
+	text = strings.TrimSpace(text)
+	ns, local, namespaced := strings.Cut(text, ":")
+	if !namespaced {
+		id, err := strconv.Atoi(text)
+		if err != nil {
+			return 0, fmt.Errorf("question %q must be an ID or \"namespace:id\"", text)
+		}
+		return id, nil
+	}
+	id, err := strconv.Atoi(local)
+	if err != nil || !slugPattern.MatchString(ns) {
+		return 0, fmt.Errorf("question %q must be an ID or \"namespace:id\"", text)
+	}
+	return namespacedID(ns, id), nil
+}
+
+// Ref returns how authors refer to the question: "namespace:id" for a
+// question from a directory bank, or its ID
+func (q Question) Ref() string {
+	if q.Namespace == "" {
+		return strconv.Itoa(q.ID)
+	}
+	return q.Namespace + ":" + strconv.Itoa(q.LocalID)
+}
+
+// bankFiles lists the question bank files in dir: every .json file that is
+// not hidden, in name order
+func bankFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%s: no .json question files", dir)
+	}
+	return paths, nil
+}
+
+// bankNamespace returns the namespace a bank file declares, or its file name
+// without the extension
+func bankNamespace(path string, data []byte) string {
+	var bank struct {
+		Namespace string `json:"namespace"`
+	}
+	if json.Unmarshal(data, &bank) == nil && bank.Namespace != "" {
+		return bank.Namespace
+	}
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// syntaxIssue reports a bank file that could not be read or parsed
+func syntaxIssue(err error) lintIssue {
+	return lintIssue{Severity: severityError, Rule: "syntax", Index: -1, Message: err.Error()}
+}
+
+// readQuestionDir reads and lints every bank file in dir and merges their
+// questions, giving each a namespaced ID. Besides each file's own issues it
+// reports invalid namespaces, files sharing a namespace and namespaced IDs
+// that collide across files. Every issue names the file it was found in.
+func readQuestionDir(dir string) ([]Question, []lintIssue, error) {
+	paths, err := bankFiles(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type origin struct {
+		path, ns  string
+		index, id int
+	}
+	namespaces := make(map[string]string)
+	ids := make(map[int]origin)
+
+	var questions []Question
+	var issues []lintIssue
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		var fileQuestions []Question
+		var fileIssues []lintIssue
+		if err == nil {
+			fileQuestions, fileIssues, err = lintQuestionBank(data)
+		}
+		if err != nil {
+			fileIssues = []lintIssue{syntaxIssue(err)}
+		}
+
+		ns := bankNamespace(path, data)
+		conflict := true
+		if other, dup := namespaces[ns]; dup {
+			fileIssues = append(fileIssues, lintIssue{Severity: severityError, Rule: "namespace-conflict", Index: -1,
+				Message: fmt.Sprintf("namespace %q is already used by %s", ns, other)})
+		} else if !slugPattern.MatchString(ns) {
+			fileIssues = append(fileIssues, lintIssue{Severity: severityError, Rule: "namespace", Index: -1,
+				Message: fmt.Sprintf("namespace %q must be lowercase letters, digits and dashes; rename the file or set \"namespace\"", ns)})
+		} else {
+			namespaces[ns] = path
+			conflict = false
+		}
+
+		// Namespaced IDs are hashes, so different questions can get the same
+		// one, in different files or in the same file. A repeated ID within
+		// a file is already reported by the file's own checks.
+		for i, q := range fileQuestions {
+			global := namespacedID(ns, q.ID)
+			if prev, dup := ids[global]; dup && !conflict && (prev.path != path || prev.id != q.ID) {
+				fileIssues = append(fileIssues, lintIssue{Severity: severityError, Rule: "duplicate-id", Index: i, ID: q.ID,
+					Message: fmt.Sprintf("namespaced id %d of %s:%d collides with %s:%d (question %d in %s); change either id", global, ns, q.ID, prev.ns, prev.id, prev.index, prev.path)})
+			} else if !dup {
+				ids[global] = origin{path, ns, i, q.ID}
+			}
+			q.ID, q.LocalID, q.Namespace = global, q.ID, ns
+			questions = append(questions, q)
+		}
+
+		for i := range fileIssues {
+			fileIssues[i].File = path
+		}
+		issues = append(issues, fileIssues...)
+	}
+	return questions, issues, nil
+}
+
+// loadQuestionDir loads a directory bank, failing with one error per file
+// that has lint errors
+func loadQuestionDir(dir string) ([]Question, error) {
+	questions, issues, err := readQuestionDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	byFile := make(map[string][]lintIssue)
+	for _, i := range issues {
+		if _, ok := byFile[i.File]; !ok {
+			files = append(files, i.File)
+		}
+		byFile[i.File] = append(byFile[i.File], i)
+	}
+
+	var errs []error
+	for _, f := range files {
+		if err := lintError(byFile[f]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	for _, f := range files {
+		logLintWarnings(f, byFile[f])
+	}
+	return questions, nil
+}
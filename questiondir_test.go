@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeBankDir creates a directory bank from file names and contents
+func writeBankDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadQuestionDir(t *testing.T) {
+	dir := writeBankDir(t, map[string]string{
+		"geography.json": `{"schema_version": 2, "questions": [
+			{"id": 1, "question": "Capital of France?", "choices": ["London", "Paris"], "answer_index": 1, "explanation": "Paris"}
+		]}`,
+		"maths.json": `{"schema_version": 2, "namespace": "math", "questions": [
+			{"id": 1, "question": "2 + 2?", "choices": ["3", "4"], "answer_index": 1, "explanation": "4"}
+		]}`,
+		"notes.txt":    "not a bank",
+		".draft.json":  "[",
+		"old.json.bak": "[",
+	})
+
+	t.Setenv("QUESTIONS_PATH", dir)
+	questions, err := loadQuestions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(questions) != 2 {
+		t.Fatalf("got %d questions, want 2", len(questions))
+	}
+	if q := questions[0]; q.ID != namespacedID("geography", 1) || q.Namespace != "geography" {
+		t.Errorf("first question = id %d namespace %q, want the geography namespace", q.ID, q.Namespace)
+	}
+	if q := questions[1]; q.ID != namespacedID("math", 1) || q.Namespace != "math" {
+		t.Errorf("second question = id %d namespace %q, want the declared math namespace", q.ID, q.Namespace)
+	}
+
+	matched := QuestionFilters{Namespaces: []string{"math"}}.apply(questions)
+	if len(matched) != 1 || matched[0].Question != "2 + 2?" {
+		t.Errorf("namespace filter matched %+v", matched)
+	}
+}
+
+func TestLoadQuestionDirErrors(t *testing.T) {
+	dir := writeBankDir(t, map[string]string{
+		"a.json": `{"schema_version": 2, "namespace": "shared", "questions": [
+			{"id": 1, "question": "Q?", "choices": ["A", "B"], "answer_index": 0, "explanation": "x"}
+		]}`,
+		"b.json": `{"schema_version": 2, "namespace": "shared", "questions": [
+			{"id": 2, "question": "Q2?", "choices": ["A", "B"], "answer_index": 0, "explanation": "x"}
+		]}`,
+		"c.json":         `{"schema_version": 2, "questions": [{"id": 1, "question": "", "choices": ["A", "B"], "explanation": "x"}]}`,
+		"World Cup.json": `{"schema_version": 2, "questions": []}`,
+	})
+
+	_, err := loadQuestionsFrom(dir)
+	if err == nil {
+		t.Fatal("loadQuestionsFrom() succeeded, want errors")
+	}
+	want := []string{
+		filepath.Join(dir, "World Cup.json") + `: namespace "World Cup" must be lowercase letters, digits and dashes; rename the file or set "namespace" [namespace]`,
+		filepath.Join(dir, "b.json") + `: namespace "shared" is already used by ` + filepath.Join(dir, "a.json") + " [namespace-conflict]",
+		filepath.Join(dir, "c.json") + ": question 0 (id=1): question text is empty [empty-question]",
+	}
+	if got := strings.Split(err.Error(), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("error:\n%s\nwant:\n%s", err, strings.Join(want, "\n"))
+	}
+
+	if _, err := loadQuestionsFrom(t.TempDir()); err == nil || !strings.Contains(err.Error(), "no .json question files") {
+		t.Errorf("empty directory error = %v", err)
+	}
+}
+
+func TestLintQuestionDir(t *testing.T) {
+	dir := writeBankDir(t, map[string]string{
+		"history.json": `[{"id": 1, "question": "Q?", "choices": ["A", "B"], "answer_index": 0, "explanation": "x"}]`,
+		"science.json": `{"schema_version": 2, "questions": [`,
+	})
+
+	var stdout, stderr bytes.Buffer
+	if code := runCommand([]string{"lint", dir}, &stdout, &stderr); code != 1 {
+		t.Errorf("lint exited %d, want 1", code)
+	}
+	out := stdout.String()
+	for _, want := range []string{
+		filepath.Join(dir, "history.json") + ": warning: schema version 1 is deprecated",
+		filepath.Join(dir, "science.json") + ": error: unexpected end of JSON input [syntax]",
+		"1 errors, 1 warnings",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("lint output should contain %q:\n%s", want, out)
+		}
+	}
+
+	// migrate upgrades each file of a directory
+	os.Remove(filepath.Join(dir, "science.json"))
+	stdout.Reset()
+	if code := runCommand([]string{"migrate", "-backup=false", dir}, &stdout, &stderr); code != 0 {
+		t.Fatalf("migrate exited %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Migrated "+filepath.Join(dir, "history.json")) {
+		t.Errorf("migrate output = %q", stdout.String())
+	}
+}
+
+func TestQuestionDirIDCollisions(t *testing.T) {
+	question := func(id int) string {
+		return fmt.Sprintf(`{"id": %d, "question": "Q%d?", "choices": ["A", "B"], "answer_index": 0, "explanation": "x"}`, id, id)
+	}
+	// geography:20498 and math:140860 hash to the same namespaced ID, as do
+	// science:724109 and science:801206
+	dir := writeBankDir(t, map[string]string{
+		"geography.json": `{"schema_version": 2, "questions": [` + question(20498) + `]}`,
+		"math.json":      `{"schema_version": 2, "questions": [` + question(140860) + `]}`,
+		"science.json":   `{"schema_version": 2, "questions": [` + question(724109) + `, ` + question(801206) + `]}`,
+	})
+	if namespacedID("geography", 20498) != namespacedID("math", 140860) || namespacedID("science", 724109) != namespacedID("science", 801206) {
+		t.Fatal("test IDs no longer collide")
+	}
+
+	_, err := loadQuestionsFrom(dir)
+	if err == nil {
+		t.Fatal("loadQuestionsFrom() succeeded, want collisions")
+	}
+	for _, want := range []string{
+		filepath.Join(dir, "math.json") + fmt.Sprintf(": question 0 (id=140860): namespaced id %d of math:140860 collides with geography:20498 (question 0 in %s)", namespacedID("math", 140860), filepath.Join(dir, "geography.json")),
+		filepath.Join(dir, "science.json") + fmt.Sprintf(": question 1 (id=801206): namespaced id %d of science:801206 collides with science:724109 (question 0 in %s)", namespacedID("science", 801206), filepath.Join(dir, "science.json")),
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should contain %q:\n%s", want, err)
+		}
+	}
+}
+
+func TestQuestionRefs(t *testing.T) {
+	dir := writeBankDir(t, map[string]string{
+		"geography.json": `{"schema_version": 2, "questions": [
+			{"id": 1, "question": "Capital of France?", "choices": ["London", "Paris"], "answer_index": 1, "explanation": "Paris"},
+			{"id": 2, "question": "Capital of Spain?", "choices": ["Madrid", "Rome"], "answer_index": 0, "explanation": "Madrid"}
+		]}`,
+	})
+	questions, err := loadQuestionsFrom(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := questions[1].Ref(); got != "geography:2" {
+		t.Errorf("Ref() = %q, want geography:2", got)
+	}
+	if got := (Question{ID: 7}).Ref(); got != "7" {
+		t.Errorf("Ref() of a file bank question = %q, want 7", got)
+	}
+
+	var def QuizDefinition
+	data := `{"filters": {"ids": ["geography:2", 5]}, "branching": {"start": "a", "nodes": {"a": {"question_id": "geography:1"}}}}`
+	if err := json.Unmarshal([]byte(data), &def); err != nil {
+		t.Fatal(err)
+	}
+	if matched := def.Filters.apply(questions); len(matched) != 1 || matched[0].LocalID != 2 {
+		t.Errorf("ids filter matched %+v", matched)
+	}
+	if resolved, err := def.Branching.resolve(questions); err != nil || resolved["a"].LocalID != 1 {
+		t.Errorf("branching resolved to %+v, %v", resolved, err)
+	}
+
+	for _, bad := range []string{`{"filters": {"ids": ["geography"]}}`, `{"filters": {"ids": ["Geo Graphy:1"]}}`, `{"filters": {"ids": [true]}}`} {
+		if err := json.Unmarshal([]byte(bad), &def); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+
+	// The admin pages accept the same references
+	t.Setenv("QUESTIONS_PATH", dir)
+	req := httptest.NewRequest(http.MethodGet, "/admin/versions?question=geography:2", nil)
+	rr := httptest.NewRecorder()
+	if q, ok := questionForm(rr, req); !ok || q.Question != "Capital of Spain?" {
+		t.Errorf("questionForm(geography:2) = %+v, %v", q, ok)
+	}
+	if !(questionListPage{Query: "Geography:1"}).matches(questions[0]) {
+		t.Error("searching by namespace:id should match")
+	}
+}
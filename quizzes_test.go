@@ -0,0 +1,173 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLoadQuizDefinitions(t *testing.T) {
+	os.Remove("quizzes.json")
+	os.Remove("quiz.json")
+
+	defs, err := loadQuizDefinitions()
+	if err != nil {
+		t.Fatalf("loadQuizDefinitions() returned error: %v", err)
+	}
+	if len(defs) != 1 || defs[0].Slug != "default" {
+		t.Errorf("without quizzes.json want the default quiz, got %+v", defs)
+	}
+
+	quizzesJSON := `[
+		{"slug": "geo", "title": "Geography", "filters": {"tags": ["geo"]}, "length": 2},
+		{"slug": "timed", "title": "Speed Round", "time_limit_seconds": 60}
+	]`
+	if err := os.WriteFile("quizzes.json", []byte(quizzesJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quizzes.json")
+
+	defs, err = loadQuizDefinitions()
+	if err != nil {
+		t.Fatalf("loadQuizDefinitions() returned error: %v", err)
+	}
+	if len(defs) != 2 {
+		t.Fatalf("got %d quizzes, want 2", len(defs))
+	}
+	timed, ok := findQuizDefinition(defs, "timed")
+	if !ok || timed.TimeLimit() != time.Minute || timed.Length != NumQuestions || timed.Mode != ModeExam {
+		t.Errorf("timed quiz should keep defaults for unset fields, got %+v", timed)
+	}
+
+	for name, body := range map[string]string{
+		"duplicate slug": `[{"slug": "a"}, {"slug": "a"}]`,
+		"missing slug":   `[{"title": "No slug"}]`,
+		"bad slug":       `[{"slug": "Not A Slug"}]`,
+		"empty list":     `[]`,
+		"negative time":  `[{"slug": "a", "time_limit_seconds": -1}]`,
+	} {
+		if err := os.WriteFile("quizzes.json", []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadQuizDefinitions(); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestQuestionFilters(t *testing.T) {
+	questions := []Question{
+		{ID: 1, Tags: []string{"geo"}, Difficulty: -1},
+		{ID: 2, Tags: []string{"geo", "history"}, Difficulty: 1},
+		{ID: 3, Tags: []string{"science"}, Difficulty: 0},
+	}
+	min := 0.0
+
+	tests := []struct {
+		name    string
+		filters QuestionFilters
+		want    []int
+	}{
+		{"empty", QuestionFilters{}, []int{1, 2, 3}},
+		{"tags", QuestionFilters{Tags: []string{"history", "science"}}, []int{2, 3}},
+		{"ids", QuestionFilters{IDs: []QuestionRef{1, 3}}, []int{1, 3}},
+		{"difficulty", QuestionFilters{Tags: []string{"geo"}, MinDifficulty: &min}, []int{2}},
+	}
+	for _, tt := range tests {
+		var got []int
+		for _, q := range tt.filters.apply(questions) {
+			got = append(got, q.ID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestQuizSlugHandler(t *testing.T) {
+	questionsJSON := `[
+		{"id": 1, "question": "Capital of France?", "choices": ["Paris", "Rome"], "answer_index": 0, "tags": ["geo"]},
+		{"id": 2, "question": "2 + 2?", "choices": ["3", "4"], "answer_index": 1, "tags": ["math"]}
+	]`
+	if err := os.WriteFile("questions.json", []byte(questionsJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("questions.json")
+
+	quizzesJSON := `[{"slug": "geo", "title": "Geography", "filters": {"tags": ["geo"]}, "time_limit_seconds": 300}]`
+	if err := os.WriteFile("quizzes.json", []byte(quizzesJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quizzes.json")
+
+	if err := os.WriteFile("quiz.html", []byte(`{{.QuizTitle}}: {{.Question.Question}} ({{.TotalQuestions}}){{if .Timed}} timed{{end}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.html")
+
+	rr := httptest.NewRecorder()
+	quizHandler(rr, httptest.NewRequest(http.MethodGet, "/quiz/geo", nil))
+	if body := rr.Body.String(); rr.Code != http.StatusOK || body != "Geography: Capital of France? (1) timed" {
+		t.Errorf("GET /quiz/geo = %d %q, want the filtered timed quiz", rr.Code, body)
+	}
+
+	rr = httptest.NewRecorder()
+	quizHandler(rr, httptest.NewRequest(http.MethodGet, "/quiz/history", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("GET /quiz/history status = %d, want %d", rr.Code, http.StatusNotFound)
+	}
+
+	sessionMux.Lock()
+	for id, s := range sessions {
+		if s.QuizSlug == "geo" {
+			delete(sessions, id)
+		}
+	}
+	sessionMux.Unlock()
+}
+
+func TestTimeLimitEndsQuiz(t *testing.T) {
+	resultsHTML := `{{if .TimedOut}}timed out{{end}}{{range .Items}}|{{.Number}}:{{if .Unanswered}}unanswered{{else}}{{.ChosenAnswer}}{{end}}{{end}}`
+	if err := os.WriteFile("results.html", []byte(resultsHTML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("results.html")
+	if err := os.WriteFile("quiz.html", []byte(`{{.Question.Question}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.html")
+
+	session := newTestSession(t, []Question{
+		{ID: 1, Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+		{ID: 2, Question: "Q2?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+	})
+	session.Deadline = time.Now().Add(time.Minute)
+
+	if rr := postAnswer(session.ID, 0, 0); rr.Code != http.StatusOK {
+		t.Fatalf("answer before the deadline status = %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	session.Deadline = time.Now().Add(-time.Second)
+	rr := postAnswer(session.ID, 1, 0)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("answer after the deadline status = %d, want %d", rr.Code, http.StatusSeeOther)
+	}
+	if !session.TimedOut || len(session.Answers) != 1 {
+		t.Errorf("late answer should end the quiz without being scored, got TimedOut=%v answers=%d", session.TimedOut, len(session.Answers))
+	}
+
+	rr = httptest.NewRecorder()
+	resultsHandler(rr, httptest.NewRequest(http.MethodGet, resultsURL(session.ID), nil))
+	if body := rr.Body.String(); body != "timed out|1:A|2:unanswered" {
+		t.Errorf("results = %q, want the second question listed as unanswered", body)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ResultItem describes how a single question was answered in a finished quiz
+type ResultItem struct {
+	Number        int
+	Question      string
+	Choices       []string
+	ChosenIndex   int
+	ChosenAnswer  string
+	CorrectIndex  int
+	CorrectAnswer string
+	IsCorrect     bool
+	Unanswered    bool
+	Confidence    Confidence
+	Points        int
+	Lifelines     []Lifeline
+	Attempts      int
+	Explanation   string
+}
+
+// Results summarizes a finished quiz session for the results page
+type Results struct {
+	SessionID  string
+	QuizTitle  string
+	Mode       QuizMode
+	Items      []ResultItem
+	Score      int
+	MaxScore   int
+	Total      int
+	Percentage float64
+	Duration   time.Duration
+	TimedOut   bool
+	PausedTime time.Duration
+	Sections   []SectionResult
+
+	// Attempt is the session's number among the player's attempts at a quiz
+	// with an attempt limit, and KeptScore the attempt whose score counts
+	Attempt     int
+	MaxAttempts int
+	KeepScore   ScorePolicy
+	KeptScore   *AttemptRecord
+
+	ShareURL string
+
+	// Calibration reports accuracy at each confidence level the player used
+	Calibration []CalibrationRow
+	// Path lists the graph nodes visited in a branching quiz
+	Path []string
+	// Adaptive quizzes report the final ability estimate in logits
+	Adaptive  bool
+	Ability   float64
+	AbilitySE float64
+}
+
+// buildResults assembles the results summary for a finished session.
+// Callers must hold sessionMux if the session is shared.
+func buildResults(session *QuizSession) Results {
+	results := Results{
+		SessionID: session.ID,
+		QuizTitle: session.QuizTitle,
+		Mode:      session.Mode,
+		Score:     session.Score,
+		MaxScore:  session.MaxScore(),
+		Total:     len(session.Questions),
+		Duration:  session.Duration().Round(time.Second),
+		TimedOut:  session.TimedOut,
+		Sections:  sectionResults(session),
+
+		PausedTime: session.pausedTime().Round(time.Second),
+
+		Attempt:     session.Attempt,
+		MaxAttempts: session.MaxAttempts,
+		KeepScore:   session.KeepScore,
+		ShareURL:    resultsURL(session.ID),
+
+		Calibration: calibration(session.Answers),
+		Path:        session.Path,
+		Adaptive:    session.Adaptive != nil,
+		Ability:     session.Ability,
+		AbilitySE:   session.AbilitySE,
+	}
+
+	for i, q := range session.Questions {
+		// Questions left when a timed quiz or section ran out are listed as
+		// unanswered
+		if i >= len(session.Answers) || session.Answers[i].Skipped {
+			results.Items = append(results.Items, ResultItem{
+				Number:        i + 1,
+				Question:      q.Question,
+				Choices:       q.Choices,
+				ChosenIndex:   -1,
+				CorrectIndex:  q.AnswerIndex,
+				CorrectAnswer: q.Choices[q.AnswerIndex],
+				Unanswered:    true,
+				Explanation:   q.Explanation,
+			})
+			continue
+		}
+
+		answer := session.Answers[i]
+		// A regrade can drop choices from a question already answered, so
+		// the chosen index may no longer name one
+		var chosen string
+		if answer.Choice >= 0 && answer.Choice < len(q.Choices) {
+			chosen = q.Choices[answer.Choice]
+		}
+		results.Items = append(results.Items, ResultItem{
+			Number:        i + 1,
+			Question:      q.Question,
+			Choices:       q.Choices,
+			ChosenIndex:   answer.Choice,
+			ChosenAnswer:  chosen,
+			CorrectIndex:  q.AnswerIndex,
+			CorrectAnswer: q.Choices[q.AnswerIndex],
+			IsCorrect:     answer.Correct,
+			Confidence:    answer.Confidence,
+			Points:        answer.Points,
+			Lifelines:     answer.Lifelines,
+			Attempts:      answer.Attempts,
+			Explanation:   q.Explanation,
+		})
+	}
+
+	if results.MaxScore > 0 {
+		results.Percentage = float64(results.Score) * 100 / float64(results.MaxScore)
+	}
+	return results
+}
+
+// resultsHandler handles the GET /results endpoint, rendering the answer
+// review for a finished session identified by a signed, shareable URL
+func resultsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID := r.URL.Query().Get("session")
+	if !verifyResults(sessionID, r.URL.Query().Get("sig")) {
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	}
+
+	sessionMux.Lock()
+	session, ok := sessions[sessionID]
+	if !ok {
+		sessionMux.Unlock()
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	session.checkDeadline(time.Now())
+	if !session.Finished() {
+		sessionMux.Unlock()
+		http.Error(w, "Quiz not finished", http.StatusConflict)
+		return
+	}
+	attempt := session.completedAttempt()
+	data := buildResults(session)
+	sessionMux.Unlock()
+
+	recordAttempt(session, attempt)
+	if data.Attempt > 0 {
+		records, err := loadAttempts(session.PlayerID, session.QuizSlug)
+		if err != nil {
+			log.Printf("Error loading attempts: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if kept, ok := keptScore(records, data.KeepScore); ok {
+			data.KeptScore = &kept
+		}
+	}
+
+	tmpl, err := template.ParseFiles("results.html")
+	if err != nil {
+		log.Printf("Error parsing template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// resultsURL returns the signed, shareable results URL for a session
+func resultsURL(sessionID string) string {
+	query := url.Values{}
+	query.Set("session", sessionID)
+	query.Set("sig", signResults(sessionID))
+	return "/results?" + query.Encode()
+}
+
+// signResults generates an HMAC signature granting read access to a session's
+// results. The "results:" prefix keeps it distinct from signState signatures.
+func signResults(sessionID string) string {
+	h := hmac.New(sha256.New, []byte(hmacSecret))
+	h.Write([]byte("results:" + sessionID))
+	return base64.URLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// verifyResults reports whether signature is a valid results signature
+func verifyResults(sessionID, signature string) bool {
+	expected := signResults(sessionID)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
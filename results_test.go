@@ -0,0 +1,189 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSession registers an exam-mode session using the default quiz
+// definition with the given questions and returns it
+func newTestSession(t *testing.T, questions []Question) *QuizSession {
+	def := defaultQuizDefinition()
+	session := &QuizSession{
+		ID:        generateSessionID() + "-" + t.Name(),
+		Mode:      def.Mode,
+		Questions: questions,
+		StartTime: time.Now().Add(-90 * time.Second),
+		Scoring:   def.Scoring,
+
+		LifelineLimits: def.Lifelines,
+	}
+	sessionMux.Lock()
+	sessions[session.ID] = session
+	sessionMux.Unlock()
+	t.Cleanup(func() {
+		sessionMux.Lock()
+		delete(sessions, session.ID)
+		sessionMux.Unlock()
+	})
+	return session
+}
+
+// postAnswer submits a choice for the given question index through answerHandler
+func postAnswer(sessionID string, questionIndex, choice int) *httptest.ResponseRecorder {
+	form := url.Values{}
+	form.Set("sessionID", sessionID)
+	form.Set("questionIndex", strconv.Itoa(questionIndex))
+	form.Set("hmacSignature", signState(sessionID, questionIndex))
+	form.Set("choice", strconv.Itoa(choice))
+
+	req := httptest.NewRequest(http.MethodPost, "/answer", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	answerHandler(rr, req)
+	return rr
+}
+
+func TestAnswerHandlerFlowToResults(t *testing.T) {
+	quizHTML := `<h1>{{.Question.Question}}</h1>`
+	if err := os.WriteFile("quiz.html", []byte(quizHTML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.html")
+
+	resultsHTML := `<p>Score: {{.Score}}/{{.Total}} ({{printf "%.0f" .Percentage}}%) in {{.Duration}}</p>
+{{range .Items}}<li>{{.Question}} you: {{.ChosenAnswer}} correct: {{.CorrectAnswer}} - {{.Explanation}}</li>{{end}}`
+	if err := os.WriteFile("results.html", []byte(resultsHTML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("results.html")
+
+	session := newTestSession(t, []Question{
+		{ID: 1, Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 0, Explanation: "Because A"},
+		{ID: 2, Question: "Q2?", Choices: []string{"C", "D"}, AnswerIndex: 1, Explanation: "Because D"},
+	})
+
+	rr := postAnswer(session.ID, 0, 0)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("first answer returned status %d, want %d", rr.Code, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), "Q2?") {
+		t.Errorf("first answer should render the next question, got %q", rr.Body.String())
+	}
+
+	rr = postAnswer(session.ID, 1, 0)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("last answer returned status %d, want %d", rr.Code, http.StatusSeeOther)
+	}
+	location := rr.Header().Get("Location")
+	if location != resultsURL(session.ID) {
+		t.Errorf("last answer redirected to %q, want %q", location, resultsURL(session.ID))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, location, nil)
+	rr = httptest.NewRecorder()
+	resultsHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("results returned status %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{"Score: 1/2 (50%)", "Q2? you: C correct: D - Because D", "Because A"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("results body missing %q, got %q", want, body)
+		}
+	}
+}
+
+func TestAnswerHandlerRejectsInvalidSignature(t *testing.T) {
+	session := newTestSession(t, []Question{
+		{ID: 1, Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+	})
+
+	form := url.Values{}
+	form.Set("sessionID", session.ID)
+	form.Set("questionIndex", "0")
+	form.Set("hmacSignature", signState(session.ID, 1))
+	form.Set("choice", "0")
+
+	req := httptest.NewRequest(http.MethodPost, "/answer", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	answerHandler(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusForbidden)
+	}
+	if session.Current != 0 {
+		t.Errorf("session should not advance on invalid signature, got Current=%d", session.Current)
+	}
+}
+
+func TestAnswerHandlerRejectsStaleQuestion(t *testing.T) {
+	session := newTestSession(t, []Question{
+		{ID: 1, Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+		{ID: 2, Question: "Q2?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+	})
+	session.Current = 1
+
+	rr := postAnswer(session.ID, 0, 0)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+}
+
+func TestResultsHandlerRequiresValidSignature(t *testing.T) {
+	session := newTestSession(t, []Question{
+		{ID: 1, Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+	})
+	session.Current = 1
+
+	req := httptest.NewRequest(http.MethodGet, "/results?session="+url.QueryEscape(session.ID)+"&sig=bogus", nil)
+	rr := httptest.NewRecorder()
+	resultsHandler(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusForbidden)
+	}
+}
+
+func TestBuildResultsPercentageAndDuration(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	session := &QuizSession{
+		ID: "results-test",
+		Questions: []Question{
+			{ID: 1, Question: "Q1?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+			{ID: 2, Question: "Q2?", Choices: []string{"A", "B"}, AnswerIndex: 1},
+			{ID: 3, Question: "Q3?", Choices: []string{"A", "B"}, AnswerIndex: 1},
+			{ID: 4, Question: "Q4?", Choices: []string{"A", "B"}, AnswerIndex: 0},
+		},
+		Answers: []Answer{
+			{QuestionID: 1, Choice: 0, Correct: true, Points: 1},
+			{QuestionID: 2, Choice: 1, Correct: true, Points: 1},
+			{QuestionID: 3, Choice: 1, Correct: true, Points: 1},
+			{QuestionID: 4, Choice: 1, Correct: false},
+		},
+		Current:   4,
+		Score:     3,
+		StartTime: start,
+		EndTime:   start.Add(2*time.Minute + 5*time.Second),
+		Scoring:   defaultScoringRules(),
+	}
+
+	results := buildResults(session)
+	if results.Percentage != 75 {
+		t.Errorf("Percentage = %v, want 75", results.Percentage)
+	}
+	if results.Duration != 2*time.Minute+5*time.Second {
+		t.Errorf("Duration = %v, want 2m5s", results.Duration)
+	}
+	if len(results.Items) != 4 || results.Items[3].IsCorrect || results.Items[3].CorrectAnswer != "A" {
+		t.Errorf("unexpected result items: %+v", results.Items)
+	}
+}
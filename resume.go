@@ -0,0 +1,195 @@
+package main
+
+import (
+	"crypto/rand"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// resumeCodeAlphabet leaves out characters that are easily confused when a
+// code is read off one screen and typed into another
+const resumeCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// resumeCodeLength is the number of characters in a resume code
+const resumeCodeLength = 8
+
+// newResumeCode generates a random resume code
+func newResumeCode() string {
+	b := make([]byte, resumeCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		// Fall back to the session ID generator's randomness rather than
+		// failing the request
+		return strings.ToUpper(randString(resumeCodeLength))
+	}
+	for i := range b {
+		b[i] = resumeCodeAlphabet[int(b[i])%len(resumeCodeAlphabet)]
+	}
+	return string(b)
+}
+
+// normalizeResumeCode uppercases a typed code and drops spaces and dashes
+func normalizeResumeCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+}
+
+// inProgressSession returns the player's unfinished session of the quiz with
+// the given slug, or nil if there is none
+func inProgressSession(player, slug string) *QuizSession {
+	sessionMux.Lock()
+	defer sessionMux.Unlock()
+
+	var latest *QuizSession
+	for _, s := range sessions {
+		if s.PlayerID != player || s.QuizSlug != slug {
+			continue
+		}
+		s.checkDeadline(time.Now())
+		if s.Finished() {
+			continue
+		}
+		if latest == nil || s.StartTime.After(latest.StartTime) {
+			latest = s
+		}
+	}
+	return latest
+}
+
+// sessionByResumeCode returns the unfinished session with the given resume
+// code, or nil if there is none
+func sessionByResumeCode(code string) *QuizSession {
+	sessionMux.Lock()
+	defer sessionMux.Unlock()
+
+	for _, s := range sessions {
+		if s.ResumeCode != code {
+			continue
+		}
+		s.checkDeadline(time.Now())
+		if s.Finished() {
+			return nil
+		}
+		return s
+	}
+	return nil
+}
+
+// resumeOffer is the template data for the page asking a returning player
+// whether to resume their quiz or start over
+type resumeOffer struct {
+	QuizTitle        string
+	QuestionNumber   int
+	TotalQuestions   int
+	Timed            bool
+	SecondsRemaining int
+	ResumeURL        string
+	RestartURL       string
+}
+
+// newResumeOffer builds the resume or start over page for a session
+func newResumeOffer(session *QuizSession) resumeOffer {
+	sessionMux.RLock()
+	defer sessionMux.RUnlock()
+
+	path := "/quiz/" + url.PathEscape(session.QuizSlug)
+	offer := resumeOffer{
+		QuizTitle:      session.QuizTitle,
+		QuestionNumber: session.Current + 1,
+		TotalQuestions: len(session.Questions),
+		ResumeURL:      path + "?action=resume",
+		RestartURL:     path + "?action=restart",
+	}
+	if deadline := session.nextDeadline(); !deadline.IsZero() {
+		// The clock of a paused session stopped when the pause began
+		now := time.Now()
+		if start := session.pausedAt(); !start.IsZero() {
+			now = start
+		}
+		offer.Timed = true
+		offer.SecondsRemaining = int(deadline.Sub(now).Seconds())
+	}
+	return offer
+}
+
+// renderResumeOffer executes the resume or start over template
+func renderResumeOffer(w http.ResponseWriter, data resumeOffer) {
+	tmpl, err := template.ParseFiles("resume.html")
+	if err != nil {
+		log.Printf("Error parsing template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// resumeSession renders the page the player left off on: the current
+// question, or the instructions of a section they have not started. Timers
+// keep their original deadlines, so the remaining time carries over.
+func resumeSession(w http.ResponseWriter, r *http.Request, session *QuizSession) {
+	sessionMux.Lock()
+	session.checkDeadline(time.Now())
+	if session.Finished() {
+		attempt := session.completedAttempt()
+		sessionMux.Unlock()
+		recordAttempt(session, attempt)
+		http.Redirect(w, r, resultsURL(session.ID), http.StatusSeeOther)
+		return
+	}
+
+	// A paused session stays paused until the player restarts the clock
+	if session.paused(time.Now()) {
+		data := newPausePage(session, time.Now())
+		sessionMux.Unlock()
+		renderPause(w, data)
+		return
+	}
+
+	// Time away from the quiz should not count as answer latency
+	session.QuestionStart = time.Now()
+	if session.awaitingSection() {
+		data := newSectionPage(session)
+		sessionMux.Unlock()
+		renderSection(w, data)
+		return
+	}
+	data := newQuestionPage(session)
+	sessionMux.Unlock()
+	renderQuestion(w, data)
+}
+
+// resumeHandler handles POST /resume, continuing the quiz with the submitted
+// resume code. The browser is given the original player's cookie so the
+// quiz can also be resumed from another device.
+func resumeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	session := sessionByResumeCode(normalizeResumeCode(r.FormValue("code")))
+	if session == nil {
+		http.Error(w, "Resume code not found", http.StatusNotFound)
+		return
+	}
+
+	setPlayerCookie(w, r, session.PlayerID)
+	resumeSession(w, r, session)
+}
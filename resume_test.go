@@ -0,0 +1,181 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResumeQuiz(t *testing.T) {
+	questionsJSON := `[
+		{"id": 1, "question": "Q1?", "choices": ["A", "B"], "answer_index": 0},
+		{"id": 2, "question": "Q2?", "choices": ["A", "B"], "answer_index": 0}
+	]`
+	if err := os.WriteFile("questions.json", []byte(questionsJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("questions.json")
+
+	if err := os.WriteFile("quiz.json", []byte(`{"title": "Timed", "length": 2, "time_limit_seconds": 600}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.json")
+
+	templates := map[string]string{
+		"quiz.html":   `{{.SessionID}} {{.QuestionNumber}}/{{.TotalQuestions}} {{.ResumeCode}}`,
+		"resume.html": `{{.QuizTitle}} at {{.QuestionNumber}}/{{.TotalQuestions}}{{if .Timed}} timed{{end}} {{.ResumeURL}} {{.RestartURL}}`,
+	}
+	for name, body := range templates {
+		if err := os.WriteFile(name, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(name)
+	}
+
+	cookie := &http.Cookie{Name: playerCookieName, Value: "resume-tester"}
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		quizHandler(rr, req)
+		return rr
+	}
+
+	fields := strings.Fields(get("/quiz").Body.String())
+	if len(fields) != 3 || fields[1] != "1/2" {
+		t.Fatalf("first visit should start the quiz, got %q", fields)
+	}
+	sessionID, code := fields[0], fields[2]
+	defer func() {
+		sessionMux.Lock()
+		delete(sessions, sessionID)
+		sessionMux.Unlock()
+	}()
+	postAnswer(sessionID, 0, 0)
+
+	sessionMux.RLock()
+	deadline := sessions[sessionID].Deadline
+	sessionMux.RUnlock()
+
+	want := "Timed at 2/2 timed /quiz/default?action=resume /quiz/default?action=restart"
+	if body := get("/quiz").Body.String(); body != want {
+		t.Errorf("returning visit = %q, want %q", body, want)
+	}
+
+	if body := get("/quiz/default?action=resume").Body.String(); body != sessionID+" 2/2 "+code {
+		t.Errorf("resume = %q, want the second question of the same session", body)
+	}
+
+	// The resume code picks the quiz up on a device without the cookie
+	form := url.Values{}
+	form.Set("code", strings.ToLower(code[:4]+"-"+code[4:]))
+	req := httptest.NewRequest(http.MethodPost, "/resume", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	resumeHandler(rr, req)
+	if body := rr.Body.String(); body != sessionID+" 2/2 "+code {
+		t.Errorf("resume by code = %q, want the second question of the same session", body)
+	}
+	if c := rr.Result().Cookies(); len(c) != 1 || c[0].Value != "resume-tester" {
+		t.Errorf("resume by code should set the player cookie, got %v", c)
+	}
+
+	sessionMux.RLock()
+	session := sessions[sessionID]
+	if session.Score != 1 || !session.Deadline.Equal(deadline) {
+		t.Errorf("resuming should keep the score and deadline, got score %d deadline %v", session.Score, session.Deadline)
+	}
+	sessionMux.RUnlock()
+
+	form.Set("code", "NOPE2345")
+	req = httptest.NewRequest(http.MethodPost, "/resume", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	resumeHandler(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("unknown resume code status = %d, want %d", rr.Code, http.StatusNotFound)
+	}
+
+	fields = strings.Fields(get("/quiz/default?action=restart").Body.String())
+	if len(fields) != 3 || fields[0] == sessionID || fields[1] != "1/2" {
+		t.Fatalf("start over should begin a new session, got %q", fields)
+	}
+	defer func() {
+		sessionMux.Lock()
+		delete(sessions, fields[0])
+		sessionMux.Unlock()
+	}()
+	sessionMux.RLock()
+	_, ok := sessions[sessionID]
+	sessionMux.RUnlock()
+	if ok {
+		t.Error("start over should discard the unfinished session")
+	}
+}
+
+func TestResumeCodeFinishedSession(t *testing.T) {
+	session := newTestSession(t, []Question{{ID: 1, Question: "Q1?", Choices: []string{"A", "B"}}})
+	session.ResumeCode = newResumeCode()
+	session.Deadline = time.Now().Add(-time.Second)
+
+	if got := sessionByResumeCode(session.ResumeCode); got != nil {
+		t.Error("a session past its deadline should not be resumable")
+	}
+	if len(session.ResumeCode) != resumeCodeLength || strings.Trim(session.ResumeCode, resumeCodeAlphabet) != "" {
+		t.Errorf("resume code %q should use %d characters from the code alphabet", session.ResumeCode, resumeCodeLength)
+	}
+}
+
+func TestRefusedRestartKeepsSession(t *testing.T) {
+	useTempVersionStores(t)
+	questionsJSON := `[{"id": 1, "question": "Q1?", "choices": ["A", "B"], "answer_index": 0}]`
+	if err := os.WriteFile("questions.json", []byte(questionsJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("questions.json")
+	if err := os.WriteFile("quiz.json", []byte(`{"title": "Final", "length": 1, "max_attempts": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.json")
+
+	templates := map[string]string{
+		"quiz.html":        `{{.SessionID}}`,
+		"unavailable.html": `{{.Message}}`,
+	}
+	for name, body := range templates {
+		if err := os.WriteFile(name, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(name)
+	}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.AddCookie(&http.Cookie{Name: playerCookieName, Value: "restart-tester"})
+		rr := httptest.NewRecorder()
+		quizHandler(rr, req)
+		return rr
+	}
+	sessionID := get("/quiz").Body.String()
+	defer func() {
+		sessionMux.Lock()
+		delete(sessions, sessionID)
+		sessionMux.Unlock()
+	}()
+
+	// The only attempt is in use, so starting over is refused and the
+	// unfinished session must survive
+	if rr := get("/quiz/default?action=restart"); rr.Code != http.StatusForbidden {
+		t.Errorf("restart with no attempts left: got %d %q", rr.Code, rr.Body.String())
+	}
+	sessionMux.RLock()
+	_, ok := sessions[sessionID]
+	sessionMux.RUnlock()
+	if !ok {
+		t.Error("refused restart discarded the unfinished session")
+	}
+}
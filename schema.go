@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// questionSchemaVersion is the questions.json schema version this build
+// writes. Version 1 files are a bare array of questions, possibly using the
+// legacy "answers" and "correct" fields; version 2 wraps the array in an
+// object with a schema_version field.
+const questionSchemaVersion = 2
+
+// questionBank is the on-disk form of a version 2 questions.json
+type questionBank struct {
+	SchemaVersion int `json:"schema_version"`
+	// Namespace names the file's questions in a directory bank; it defaults
+	// to the file name
+	Namespace string     `json:"namespace,omitempty"`
+	Questions []Question `json:"questions"`
+}
+
+// bankOnlyKeys are question fields questions.json supports that CSV and YAML
+// records do not
+var bankOnlyKeys = map[string]bool{"version": true, "template": true, "retired": true, "author": true}
+
+// legacyFields lists the version 1 field names and the fields that replaced
+// them
+var legacyFields = []struct{ Old, New string }{
+	{"answers", "choices"},
+	{"correct", "answer_index"},
+}
+
+// decodeQuestionBank parses a question bank of any supported schema version.
+// Deprecated usage and unknown fields are accepted and reported as warnings;
+// using a legacy field alongside its replacement, or in a version 2 file, is
+// an error rather than being silently ignored.
+func decodeQuestionBank(data []byte) ([]Question, int, []lintIssue, error) {
+	version := 1
+	var items []map[string]json.RawMessage
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, 0, nil, err
+		}
+	} else {
+		var bank struct {
+			SchemaVersion int                          `json:"schema_version"`
+			Questions     []map[string]json.RawMessage `json:"questions"`
+		}
+		if err := json.Unmarshal(data, &bank); err != nil {
+			return nil, 0, nil, err
+		}
+		switch {
+		case bank.SchemaVersion == 0:
+			return nil, 0, nil, errors.New("schema_version is missing")
+		case bank.SchemaVersion < 0 || bank.SchemaVersion > questionSchemaVersion:
+			return nil, 0, nil, fmt.Errorf("schema_version %d is not supported (this build reads versions 1 to %d)", bank.SchemaVersion, questionSchemaVersion)
+		}
+		version, items = bank.SchemaVersion, bank.Questions
+	}
+
+	questions := make([]Question, len(items))
+	var issues []lintIssue
+	legacy := 0
+	for i, item := range items {
+		renamed := false
+		for _, f := range legacyFields {
+			value, ok := item[f.Old]
+			if !ok {
+				continue
+			}
+			if version >= 2 {
+				return nil, 0, nil, fmt.Errorf("question %d: %q was renamed to %q in schema version 2", i, f.Old, f.New)
+			}
+			if _, ok := item[f.New]; ok {
+				return nil, 0, nil, fmt.Errorf("question %d: has both %q and its replacement %q", i, f.Old, f.New)
+			}
+			item[f.New] = value
+			delete(item, f.Old)
+			renamed = true
+		}
+		if renamed {
+			legacy++
+		}
+
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		if err := json.Unmarshal(data, &questions[i]); err != nil {
+			return nil, 0, nil, fmt.Errorf("question %d: %w", i, err)
+		}
+
+		var unknown []string
+		for key := range item {
+			if !recordKeys[key] && !bankOnlyKeys[key] {
+				unknown = append(unknown, key)
+			}
+		}
+		sort.Strings(unknown)
+		for _, key := range unknown {
+			issues = append(issues, lintIssue{Severity: severityWarning, Rule: "unknown-field", Index: i, ID: questions[i].ID,
+				Message: fmt.Sprintf("unknown field %q is ignored", key)})
+		}
+	}
+
+	if version < questionSchemaVersion {
+		issues = append(issues, lintIssue{Severity: severityWarning, Rule: "deprecated-schema", Index: -1,
+			Message: fmt.Sprintf("schema version %d is deprecated; run the migrate command to upgrade to version %d", version, questionSchemaVersion)})
+	}
+	if legacy > 0 {
+		issues = append(issues, lintIssue{Severity: severityWarning, Rule: "legacy-field", Index: -1,
+			Message: fmt.Sprintf("%d questions use the deprecated \"answers\"/\"correct\" fields; use \"choices\"/\"answer_index\"", legacy)})
+	}
+	return questions, version, issues, nil
+}
+
+// readQuestionBank reads the question bank at path without validating it, as
+// the import command does before merging. A missing file is an empty bank.
+func readQuestionBank(path string) ([]Question, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	questions, _, issues, err := decodeQuestionBank(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	logLintWarnings(path, issues)
+	return questions, nil
+}
+
+// writeQuestionBank atomically writes questions to path in the current
+// schema version
+func writeQuestionBank(path string, questions []Question) error {
+	if questions == nil {
+		questions = []Question{}
+	}
+	return writeJSONFile(path, questionBank{SchemaVersion: questionSchemaVersion, Questions: questions})
+}
+
+// runMigrate implements the migrate command, which rewrites question banks
+// in the current schema version. The original file is kept with a .bak
+// suffix unless -backup=false is given.
+func runMigrate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	backup := fs.Bool("backup", true, "keep a copy of each original file as FILE.bak")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{questionBankPath()}
+	}
+
+	// A directory bank is migrated file by file
+	var files []string
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			dirFiles, err := bankFiles(path)
+			if err != nil {
+				fmt.Fprintf(stderr, "migrate: %v\n", err)
+				return 1
+			}
+			files = append(files, dirFiles...)
+			continue
+		}
+		files = append(files, path)
+	}
+
+	status := 0
+	for _, path := range files {
+		if err := migrateFile(path, *backup, stdout); err != nil {
+			fmt.Fprintf(stderr, "migrate: %s: %v\n", path, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// migrateFile upgrades one question bank, leaving it untouched if it is
+// already current or does not pass validation
+func migrateFile(path string, backup bool, stdout io.Writer) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	questions, version, _, err := decodeQuestionBank(data)
+	if err != nil {
+		return err
+	}
+	if version == questionSchemaVersion {
+		fmt.Fprintf(stdout, "%s is already at schema version %d\n", path, version)
+		return nil
+	}
+	if err := lintError(lintQuestions(questions)); err != nil {
+		return err
+	}
+
+	if backup {
+		if err := os.WriteFile(path+".bak", data, 0644); err != nil {
+			return err
+		}
+	}
+	if err := writeQuestionBank(path, questions); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Migrated %s from schema version %d to %d (%d questions)\n", path, version, questionSchemaVersion, len(questions))
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeQuestionBank(t *testing.T) {
+	legacy := `[
+		{"id": 1, "question": "Capital of France?", "answers": ["London", "Paris"], "correct": 1},
+		{"id": 2, "question": "2 + 2?", "choices": ["3", "4"], "answer_index": 1}
+	]`
+	questions, version, warnings, err := decodeQuestionBank([]byte(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Question{
+		{ID: 1, Question: "Capital of France?", Choices: []string{"London", "Paris"}, AnswerIndex: 1},
+		{ID: 2, Question: "2 + 2?", Choices: []string{"3", "4"}, AnswerIndex: 1},
+	}
+	if version != 1 || !reflect.DeepEqual(questions, want) {
+		t.Errorf("legacy bank = version %d, %+v", version, questions)
+	}
+	if len(warnings) != 2 || warnings[0].Rule != "deprecated-schema" || warnings[1].Rule != "legacy-field" || !strings.Contains(warnings[1].Message, "1 questions use") {
+		t.Errorf("warnings = %q", warnings)
+	}
+
+	current := `{"schema_version": 2, "questions": [{"id": 2, "question": "2 + 2?", "choices": ["3", "4"], "answer_index": 1}]}`
+	questions, version, warnings, err = decodeQuestionBank([]byte(current))
+	if err != nil || version != 2 || len(warnings) != 0 || !reflect.DeepEqual(questions, want[1:]) {
+		t.Errorf("current bank = %+v, version %d, warnings %q, err %v", questions, version, warnings, err)
+	}
+
+	bad := map[string]string{
+		`[{"answers": ["A", "B"], "choices": ["A", "B"]}]`:                            `question 0: has both "answers" and its replacement "choices"`,
+		`{"schema_version": 2, "questions": [{"choices": ["A", "B"], "correct": 1}]}`: `question 0: "correct" was renamed to "answer_index" in schema version 2`,
+		`{"questions": []}`:                      "schema_version is missing",
+		`{"schema_version": 3, "questions": []}`: "schema_version 3 is not supported (this build reads versions 1 to 2)",
+		`[{"id": "one"}]`:                        "question 0: json: cannot unmarshal string into Go struct field Question.id of type int",
+	}
+	for input, msg := range bad {
+		if _, _, _, err := decodeQuestionBank([]byte(input)); err == nil || err.Error() != msg {
+			t.Errorf("decodeQuestionBank(%s) error = %v, want %q", input, err, msg)
+		}
+	}
+}
+
+func TestLoadQuestionsLegacy(t *testing.T) {
+	legacy := `[{"id": 1, "question": "Q?", "answers": ["A", "B", "C"], "correct": 2}]`
+	path := filepath.Join(t.TempDir(), "questions.json")
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	questions, err := loadQuestionsFrom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(questions) != 1 || len(questions[0].Choices) != 3 || questions[0].AnswerIndex != 2 {
+		t.Errorf("legacy fields were not loaded: %+v", questions)
+	}
+}
+
+func TestRunMigrate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "questions.json")
+	legacy := `[{"id": 1, "question": "Q?", "answers": ["A", "B"], "correct": 1}]`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runCommand([]string{"migrate", path}, &stdout, &stderr); code != 0 {
+		t.Fatalf("migrate exited %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "from schema version 1 to 2 (1 questions)") {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+	if data, err := os.ReadFile(path + ".bak"); err != nil || string(data) != legacy {
+		t.Errorf("backup = %q, %v; want the original file", data, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	questions, version, warnings, err := decodeQuestionBank(data)
+	if err != nil || version != questionSchemaVersion || len(warnings) != 0 {
+		t.Fatalf("migrated file = version %d, warnings %q, err %v", version, warnings, err)
+	}
+	if len(questions) != 1 || questions[0].Choices[1] != "B" || questions[0].AnswerIndex != 1 {
+		t.Errorf("migrated questions = %+v", questions)
+	}
+
+	// Migrating again leaves the file alone
+	stdout.Reset()
+	if code := runCommand([]string{"migrate", path}, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "already at schema version 2") {
+		t.Errorf("second migrate exited %d: %q", code, stdout.String())
+	}
+
+	// A bank that fails validation is not rewritten
+	invalid := `[{"id": 1, "question": "Q?", "answers": ["A", "B"], "correct": 4}]`
+	if err := os.WriteFile(path, []byte(invalid), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stderr.Reset()
+	if code := runCommand([]string{"migrate", "-backup=false", path}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "out of bounds") {
+		t.Errorf("invalid migrate exited %d: %q", code, stderr.String())
+	}
+	if data, _ := os.ReadFile(path); string(data) != invalid {
+		t.Errorf("invalid bank was rewritten: %s", data)
+	}
+}
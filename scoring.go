@@ -0,0 +1,78 @@
+package main
+
+import "fmt"
+
+// ScoringRules sets how many points an answer is worth and how much each
+// lifeline costs. Penalties reduce the points a correct answer earns but never
+// push it below the points for an incorrect answer. With ConfidenceMarking
+// enabled, the certainty-based marking table replaces Correct and Incorrect.
+type ScoringRules struct {
+	Correct           int  `json:"correct"`
+	Incorrect         int  `json:"incorrect"`
+	FiftyFiftyPenalty int  `json:"fifty_fifty_penalty"`
+	HintPenalty       int  `json:"hint_penalty"`
+	ConfidenceMarking bool `json:"confidence_marking"`
+}
+
+// defaultScoringRules awards one point per correct answer and makes a
+// lifeline-assisted correct answer worth nothing
+func defaultScoringRules() ScoringRules {
+	return ScoringRules{
+		Correct:           1,
+		Incorrect:         0,
+		FiftyFiftyPenalty: 1,
+		HintPenalty:       1,
+	}
+}
+
+// marks returns the points for a correct and an incorrect answer at the
+// given confidence
+func (s ScoringRules) marks(confidence Confidence) (correct, incorrect int) {
+	if s.ConfidenceMarking {
+		m := certaintyMarkingTable[confidence]
+		return m.Correct, m.Incorrect
+	}
+	return s.Correct, s.Incorrect
+}
+
+// maxPoints returns the most a single answer can earn
+func (s ScoringRules) maxPoints() int {
+	if s.ConfidenceMarking {
+		return certaintyMarkingTable[ConfidenceHigh].Correct
+	}
+	return s.Correct
+}
+
+// points returns the score for an answer given the stated confidence and the
+// lifelines used on it
+func (s ScoringRules) points(correct bool, confidence Confidence, lifelines []Lifeline) int {
+	correctPoints, incorrectPoints := s.marks(confidence)
+	if !correct {
+		return incorrectPoints
+	}
+
+	points := correctPoints
+	for _, l := range lifelines {
+		switch l {
+		case LifelineFiftyFifty:
+			points -= s.FiftyFiftyPenalty
+		case LifelineHint:
+			points -= s.HintPenalty
+		}
+	}
+	if points < incorrectPoints {
+		points = incorrectPoints
+	}
+	return points
+}
+
+// validate checks that the rules can be applied consistently
+func (s ScoringRules) validate() error {
+	if s.Correct <= s.Incorrect {
+		return fmt.Errorf("scoring: correct points (%d) must exceed incorrect points (%d)", s.Correct, s.Incorrect)
+	}
+	if s.FiftyFiftyPenalty < 0 || s.HintPenalty < 0 {
+		return fmt.Errorf("scoring: lifeline penalties must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Section is one part of a quiz definition, drawing its own questions and
+// shown after an instructions page with its own optional time limit
+type Section struct {
+	Title        string          `json:"title"`
+	Instructions string          `json:"instructions"`
+	Filters      QuestionFilters `json:"filters"`
+	Length       int             `json:"length"`
+
+	// TimeLimitSeconds ends the section once it has run this long; 0 means
+	// the section is untimed
+	TimeLimitSeconds int `json:"time_limit_seconds"`
+}
+
+// validate checks that a section can be run
+func (s Section) validate() error {
+	if s.Title == "" {
+		return fmt.Errorf("section has no title")
+	}
+	if s.Length <= 0 {
+		return fmt.Errorf("section %q: length must be positive", s.Title)
+	}
+	if s.TimeLimitSeconds < 0 {
+		return fmt.Errorf("section %q: time_limit_seconds must not be negative", s.Title)
+	}
+	return nil
+}
+
+// SessionSection is a section as served in a session, covering Count
+// questions of the session starting at Start
+type SessionSection struct {
+	Title        string
+	Instructions string
+	Start        int
+	Count        int
+	TimeLimit    time.Duration
+	// TimedOut is set when the section's time ran out before every question
+	// was answered
+	TimedOut bool
+}
+
+// SectionResult is the subtotal for one section on the results page
+type SectionResult struct {
+	Title       string
+	FirstNumber int
+	LastNumber  int
+	Score       int
+	MaxScore    int
+	Correct     int
+	Answered    int
+	Total       int
+	TimedOut    bool
+}
+
+// selectSectionQuestions picks each section's questions from the pool in
+// order. A question chosen for one section is not offered to later ones.
+// Nothing is recorded as seen until the caller saves the picker, so a section
+// that matches no questions leaves the player's history untouched.
+func selectSectionQuestions(picker *unseenPicker, bank, pool []Question, sections []Section) ([]Question, []SessionSection, error) {
+	var selected []Question
+	var served []SessionSection
+	taken := make(map[int]bool)
+	for _, sec := range sections {
+		var available []Question
+		for _, q := range sec.Filters.apply(pool) {
+			if !taken[q.ID] {
+				available = append(available, q)
+			}
+		}
+		if len(available) == 0 {
+			return nil, nil, fmt.Errorf("section %q matches no questions", sec.Title)
+		}
+
+		questions := picker.pick(bank, available, sec.Length)
+		for _, q := range questions {
+			taken[q.ID] = true
+		}
+		served = append(served, SessionSection{
+			Title:        sec.Title,
+			Instructions: sec.Instructions,
+			Start:        len(selected),
+			Count:        len(questions),
+			TimeLimit:    time.Duration(sec.TimeLimitSeconds) * time.Second,
+		})
+		selected = append(selected, questions...)
+	}
+	return selected, served, nil
+}
+
+// awaitingSection reports whether the player is on a section's instructions
+// page and has not yet started its questions
+func (s *QuizSession) awaitingSection() bool {
+	return len(s.Sections) > 0 && !s.SectionStarted && !s.Finished()
+}
+
+// startSection begins the current section's questions and its timer
+func (s *QuizSession) startSection(now time.Time) {
+	s.SectionStarted = true
+	s.QuestionStart = now
+	s.SectionDeadline = time.Time{}
+	if limit := s.Sections[s.SectionIndex].TimeLimit; limit > 0 {
+		s.SectionDeadline = now.Add(limit)
+	}
+}
+
+// advanceSection moves on to the next section's instructions once the
+// current question has passed the end of its section
+func (s *QuizSession) advanceSection() {
+	if len(s.Sections) == 0 || s.SectionIndex >= len(s.Sections) {
+		return
+	}
+	sec := s.Sections[s.SectionIndex]
+	if s.Current < sec.Start+sec.Count {
+		return
+	}
+	s.SectionIndex++
+	s.SectionStarted = false
+	s.SectionDeadline = time.Time{}
+}
+
+// checkSectionDeadline skips the rest of a section whose time has run out,
+// recording its remaining questions as unanswered, and reports whether it did
+func (s *QuizSession) checkSectionDeadline(now time.Time) bool {
+	if !s.SectionStarted || s.SectionDeadline.IsZero() || now.Before(s.SectionDeadline) || s.Finished() {
+		return false
+	}
+
+	deadline := s.SectionDeadline
+	sec := &s.Sections[s.SectionIndex]
+	sec.TimedOut = true
+	for ; s.Current < sec.Start+sec.Count; s.Current++ {
+		// A practice-mode question already attempted keeps its answer
+		if len(s.Answers) > s.Current {
+			continue
+		}
+		s.Answers = append(s.Answers, Answer{
+			QuestionID: s.Questions[s.Current].ID,
+			Choice:     -1,
+			Skipped:    true,
+		})
+	}
+	s.advanceSection()
+	if s.Finished() {
+		s.EndTime = deadline
+	}
+	return true
+}
+
+// nextDeadline returns whichever of the quiz and section deadlines comes
+// first, or the zero time when neither is set
+func (s *QuizSession) nextDeadline() time.Time {
+	deadline := s.Deadline
+	if !s.SectionDeadline.IsZero() && (deadline.IsZero() || s.SectionDeadline.Before(deadline)) {
+		deadline = s.SectionDeadline
+	}
+	return deadline
+}
+
+// sectionResults totals the score for each section of the session
+func sectionResults(session *QuizSession) []SectionResult {
+	var results []SectionResult
+	for _, sec := range session.Sections {
+		result := SectionResult{
+			Title:       sec.Title,
+			FirstNumber: sec.Start + 1,
+			LastNumber:  sec.Start + sec.Count,
+			MaxScore:    sec.Count * session.Scoring.maxPoints(),
+			Total:       sec.Count,
+			TimedOut:    sec.TimedOut,
+		}
+		for i := sec.Start; i < sec.Start+sec.Count && i < len(session.Answers); i++ {
+			answer := session.Answers[i]
+			if answer.Skipped {
+				continue
+			}
+			result.Answered++
+			result.Score += answer.Points
+			if answer.Correct {
+				result.Correct++
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// sectionPage is the template data for a section's instructions page
+type sectionPage struct {
+	QuizTitle        string
+	ResumeCode       string
+	SessionID        string
+	QuestionIndex    int
+	HMACSignature    string
+	SectionNumber    int
+	TotalSections    int
+	Title            string
+	Instructions     string
+	Questions        int
+	TimeLimitSeconds int
+	// Feedback carries practice-mode feedback on the answer that ended the
+	// previous section
+	Feedback *AnswerFeedback
+}
+
+// newSectionPage builds the instructions page for the session's current
+// section
+func newSectionPage(session *QuizSession) sectionPage {
+	sec := session.Sections[session.SectionIndex]
+	return sectionPage{
+		QuizTitle:        session.QuizTitle,
+		ResumeCode:       session.ResumeCode,
+		SessionID:        session.ID,
+		QuestionIndex:    session.Current,
+		HMACSignature:    signState(session.ID, session.Current),
+		SectionNumber:    session.SectionIndex + 1,
+		TotalSections:    len(session.Sections),
+		Title:            sec.Title,
+		Instructions:     sec.Instructions,
+		Questions:        sec.Count,
+		TimeLimitSeconds: int(sec.TimeLimit.Seconds()),
+	}
+}
+
+// renderSection executes the section instructions template
+func renderSection(w http.ResponseWriter, data sectionPage) {
+	tmpl, err := template.ParseFiles("section.html")
+	if err != nil {
+		log.Printf("Error parsing template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// sectionHandler handles POST /section, sent from a section's instructions
+// page to start its questions and timer
+func sectionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	sessionID := r.FormValue("sessionID")
+	questionIndex, err := strconv.Atoi(r.FormValue("questionIndex"))
+	if err != nil {
+		http.Error(w, "Invalid question index", http.StatusBadRequest)
+		return
+	}
+
+	if !verifyState(sessionID, questionIndex, r.FormValue("hmacSignature")) {
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	}
+
+	sessionMux.Lock()
+	session, ok := sessions[sessionID]
+	if !ok {
+		sessionMux.Unlock()
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	session.checkDeadline(time.Now())
+	if session.Finished() {
+		attempt := session.completedAttempt()
+		sessionMux.Unlock()
+		recordAttempt(session, attempt)
+		http.Redirect(w, r, resultsURL(sessionID), http.StatusSeeOther)
+		return
+	}
+
+	if questionIndex != session.Current || !session.awaitingSection() {
+		sessionMux.Unlock()
+		http.Error(w, "Section already started", http.StatusConflict)
+		return
+	}
+
+	if session.paused(time.Now()) {
+		sessionMux.Unlock()
+		http.Error(w, "Quiz is paused", http.StatusConflict)
+		return
+	}
+
+	session.startSection(time.Now())
+	data := newQuestionPage(session)
+	sessionMux.Unlock()
+
+	renderQuestion(w, data)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postSection starts the section waiting at the given question index
+func postSection(sessionID string, questionIndex int) *httptest.ResponseRecorder {
+	form := url.Values{}
+	form.Set("sessionID", sessionID)
+	form.Set("questionIndex", fmt.Sprint(questionIndex))
+	form.Set("hmacSignature", signState(sessionID, questionIndex))
+	req := httptest.NewRequest(http.MethodPost, "/section", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	sectionHandler(rr, req)
+	return rr
+}
+
+func TestSectionValidation(t *testing.T) {
+	tests := map[string]string{
+		"missing title":   `{"sections": [{"length": 1}]}`,
+		"zero length":     `{"sections": [{"title": "Theory"}]}`,
+		"negative time":   `{"sections": [{"title": "Theory", "length": 1, "time_limit_seconds": -5}]}`,
+		"with study mode": `{"spaced_repetition": true, "sections": [{"title": "Theory", "length": 1}]}`,
+	}
+	defer os.Remove("quiz.json")
+	for name, body := range tests {
+		if err := os.WriteFile("quiz.json", []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadQuizDefinition(); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestSectionedQuiz(t *testing.T) {
+	questionsJSON := `[
+		{"id": 1, "question": "Theory 1?", "choices": ["A", "B"], "answer_index": 0, "tags": ["theory"]},
+		{"id": 2, "question": "Theory 2?", "choices": ["A", "B"], "answer_index": 0, "tags": ["theory"]},
+		{"id": 3, "question": "Scenario 1?", "choices": ["A", "B"], "answer_index": 1, "tags": ["scenario"]},
+		{"id": 4, "question": "Scenario 2?", "choices": ["A", "B"], "answer_index": 1, "tags": ["scenario"]}
+	]`
+	if err := os.WriteFile("questions.json", []byte(questionsJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("questions.json")
+
+	quizJSON := `{"title": "Certification", "sections": [
+		{"title": "Theory", "instructions": "Answer from memory.", "filters": {"tags": ["theory"]}, "length": 1},
+		{"title": "Scenarios", "instructions": "Read carefully.", "filters": {"tags": ["scenario"]}, "length": 2, "time_limit_seconds": 600}
+	]}`
+	if err := os.WriteFile("quiz.json", []byte(quizJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.json")
+
+	templates := map[string]string{
+		"section.html": `{{.SectionNumber}}/{{.TotalSections}} {{.Title}}: {{.Instructions}} [{{.SessionID}}]`,
+		"quiz.html":    `{{.SectionTitle}}: {{.Question.Question}}{{if .Timed}} timed{{end}}`,
+		"results.html": `{{range .Sections}}|{{.Title}} {{.Score}}/{{.MaxScore}} answered {{.Answered}}{{if .TimedOut}} timed out{{end}}{{end}}`,
+	}
+	for name, body := range templates {
+		if err := os.WriteFile(name, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(name)
+	}
+
+	rr := httptest.NewRecorder()
+	quizHandler(rr, httptest.NewRequest(http.MethodGet, "/quiz", nil))
+	if !strings.HasPrefix(rr.Body.String(), "1/2 Theory: Answer from memory.") {
+		t.Fatalf("quiz should open on the first section's instructions, got %q", rr.Body.String())
+	}
+
+	var session *QuizSession
+	sessionMux.RLock()
+	for id, s := range sessions {
+		if strings.Contains(rr.Body.String(), id) {
+			session = s
+		}
+	}
+	sessionMux.RUnlock()
+	if session == nil {
+		t.Fatal("could not find the session started by quizHandler")
+	}
+	defer func() {
+		sessionMux.Lock()
+		delete(sessions, session.ID)
+		sessionMux.Unlock()
+	}()
+
+	if rr := postAnswer(session.ID, 0, 0); rr.Code != http.StatusConflict {
+		t.Errorf("answering before the section starts status = %d, want %d", rr.Code, http.StatusConflict)
+	}
+
+	rr = postSection(session.ID, 0)
+	if body := rr.Body.String(); body != "Theory: Theory 1?" && body != "Theory: Theory 2?" {
+		t.Fatalf("starting the section should show its first question, got %q", body)
+	}
+	if rr := postSection(session.ID, 0); rr.Code != http.StatusConflict {
+		t.Errorf("starting a section twice status = %d, want %d", rr.Code, http.StatusConflict)
+	}
+
+	rr = postAnswer(session.ID, 0, 0)
+	if !strings.HasPrefix(rr.Body.String(), "2/2 Scenarios: Read carefully.") {
+		t.Fatalf("finishing a section should show the next section's instructions, got %q", rr.Body.String())
+	}
+
+	rr = postSection(session.ID, 1)
+	if !strings.HasSuffix(rr.Body.String(), " timed") {
+		t.Errorf("a timed section should show its countdown, got %q", rr.Body.String())
+	}
+	if session.SectionDeadline.IsZero() {
+		t.Fatal("starting a timed section should set its deadline")
+	}
+
+	// Let the section's time run out before its second question is answered
+	postAnswer(session.ID, 1, 1)
+	session.SectionDeadline = time.Now().Add(-time.Second)
+	if rr := postAnswer(session.ID, 2, 1); rr.Code != http.StatusSeeOther {
+		t.Fatalf("answer after the section deadline status = %d, want %d", rr.Code, http.StatusSeeOther)
+	}
+	if !session.Finished() || !session.Answers[2].Skipped {
+		t.Errorf("late answer should be skipped and end the quiz, got answers %+v", session.Answers)
+	}
+
+	rr = httptest.NewRecorder()
+	resultsHandler(rr, httptest.NewRequest(http.MethodGet, resultsURL(session.ID), nil))
+	want := "|Theory 1/1 answered 1|Scenarios 1/2 answered 1 timed out"
+	if body := rr.Body.String(); body != want {
+		t.Errorf("results = %q, want %q", body, want)
+	}
+}
+
+func TestEmptySectionLeavesHistoryAndAttempts(t *testing.T) {
+	useTempVersionStores(t)
+	path := seenQuestionsPath
+	seenQuestionsPath = filepath.Join(t.TempDir(), "seen_questions.json")
+	defer func() { seenQuestionsPath = path }()
+
+	questionsJSON := `[
+		{"id": 1, "question": "Theory 1?", "choices": ["A", "B"], "answer_index": 0, "tags": ["theory"]},
+		{"id": 2, "question": "Theory 2?", "choices": ["A", "B"], "answer_index": 0, "tags": ["theory"]}
+	]`
+	if err := os.WriteFile("questions.json", []byte(questionsJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("questions.json")
+
+	// The second section matches nothing, so the quiz cannot start
+	quizJSON := `{"title": "Certification", "max_attempts": 1, "sections": [
+		{"title": "Theory", "filters": {"tags": ["theory"]}, "length": 1},
+		{"title": "Scenarios", "filters": {"tags": ["scenario"]}, "length": 1}
+	]}`
+	if err := os.WriteFile("quiz.json", []byte(quizJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.json")
+
+	req := httptest.NewRequest(http.MethodGet, "/quiz", nil)
+	req.AddCookie(&http.Cookie{Name: playerCookieName, Value: "section-tester"})
+	rr := httptest.NewRecorder()
+	quizHandler(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("quiz with an empty section: got %d %q", rr.Code, rr.Body.String())
+	}
+
+	history := make(map[string][]int)
+	if err := readJSONFile(seenQuestionsPath, &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history["section-tester"]) != 0 {
+		t.Errorf("failed start marked questions as seen: %v", history["section-tester"])
+	}
+	if records, err := loadAttempts("section-tester", "default"); err != nil || len(records) != 0 {
+		t.Errorf("failed start used an attempt: %+v, %v", records, err)
+	}
+}
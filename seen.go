@@ -0,0 +1,112 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// seenQuestionsPath is the file each player's recently served question IDs
+// are kept in
+var seenQuestionsPath = "seen_questions.json"
+
+// seenMux serializes read-modify-write cycles on the seen questions file
+var seenMux sync.Mutex
+
+// unseenPicker randomly selects questions the player has not been served in
+// the current cycle. Once fewer unseen questions remain than are asked for,
+// the pool is exhausted: a new cycle starts with the remaining unseen
+// questions, topped up with random ones from the old cycle. Cycles are
+// tracked per pool, so exhausting one pool does not forget what the player
+// has seen elsewhere in the bank. Picks are only recorded as served when
+// saved, so a quiz can pick several sections and claim an attempt before
+// anything is marked as seen.
+type unseenPicker struct {
+	player string
+	served []int
+}
+
+// newUnseenPicker starts from the player's stored seen history
+func newUnseenPicker(player string) (*unseenPicker, error) {
+	seenMux.Lock()
+	defer seenMux.Unlock()
+
+	history := make(map[string][]int)
+	if err := readJSONFile(seenQuestionsPath, &history); err != nil {
+		return nil, err
+	}
+	return &unseenPicker{player: player, served: history[player]}, nil
+}
+
+// pick selects n questions from pool, updating the picker's history
+func (p *unseenPicker) pick(bank, pool []Question, n int) []Question {
+	inBank := make(map[int]bool, len(bank))
+	for _, q := range bank {
+		inBank[q.ID] = true
+	}
+
+	// Forget questions that have since been removed from the bank
+	var served []int
+	seen := make(map[int]bool)
+	for _, id := range p.served {
+		if inBank[id] && !seen[id] {
+			served = append(served, id)
+			seen[id] = true
+		}
+	}
+
+	var unseen, repeats []Question
+	inPool := make(map[int]bool, len(pool))
+	for _, q := range pool {
+		inPool[q.ID] = true
+		if seen[q.ID] {
+			repeats = append(repeats, q)
+		} else {
+			unseen = append(unseen, q)
+		}
+	}
+
+	selected := selectRandomQuestions(unseen, n)
+	if len(selected) < n {
+		var kept []int
+		for _, id := range served {
+			if !inPool[id] {
+				kept = append(kept, id)
+			}
+		}
+		served = kept
+		selected = append(selected, selectRandomQuestions(repeats, n-len(selected))...)
+	}
+	for _, q := range selected {
+		served = append(served, q.ID)
+	}
+
+	p.served = served
+	return shuffleQuestions(selected)
+}
+
+// save records the picked questions as served to the player
+func (p *unseenPicker) save() error {
+	seenMux.Lock()
+	defer seenMux.Unlock()
+
+	history := make(map[string][]int)
+	if err := readJSONFile(seenQuestionsPath, &history); err != nil {
+		return err
+	}
+	history[p.player] = p.served
+	return writeJSONFile(seenQuestionsPath, history)
+}
+
+// shuffleQuestions returns the questions in random order. selectRandomQuestions
+// returns its input as is when asked for all of it, which would serve the end
+// of each cycle in bank order.
+func shuffleQuestions(questions []Question) []Question {
+	shuffled := make([]Question, len(questions))
+	copy(shuffled, questions)
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	r.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return shuffled
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// pickUnseen picks n questions from pool for the player and saves them as
+// served, the way starting a quiz does
+func pickUnseen(t *testing.T, player string, bank, pool []Question, n int) []Question {
+	t.Helper()
+	picker, err := newUnseenPicker(player)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selected := picker.pick(bank, pool, n)
+	if err := picker.save(); err != nil {
+		t.Fatal(err)
+	}
+	return selected
+}
+
+func TestUnseenPickerCyclesThroughBank(t *testing.T) {
+	path := seenQuestionsPath
+	seenQuestionsPath = filepath.Join(t.TempDir(), "seen_questions.json")
+	defer func() { seenQuestionsPath = path }()
+
+	var questions []Question
+	for i := 1; i <= 7; i++ {
+		questions = append(questions, Question{ID: i, Question: "Q?", Choices: []string{"A", "B"}})
+	}
+
+	served := make(map[int]int)
+	for round := 0; round < 2; round++ {
+		selected := pickUnseen(t, "player-1", questions, questions, 3)
+		for _, q := range selected {
+			served[q.ID]++
+		}
+	}
+	if len(served) != 6 {
+		t.Fatalf("first two quizzes should serve 6 distinct questions, got %v", served)
+	}
+
+	// Only one unseen question is left, so the third quiz must include it
+	// and start a new cycle
+	var last int
+	for _, q := range questions {
+		if served[q.ID] == 0 {
+			last = q.ID
+		}
+	}
+	selected := pickUnseen(t, "player-1", questions, questions, 3)
+	found := false
+	for _, q := range selected {
+		if q.ID == last {
+			found = true
+		}
+	}
+	if len(selected) != 3 || !found {
+		t.Errorf("exhausted bank should serve the last unseen question %d and reset, got %v", last, selected)
+	}
+
+	history := make(map[string][]int)
+	if err := readJSONFile(seenQuestionsPath, &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history["player-1"]) != 3 {
+		t.Errorf("new cycle should only remember the 3 questions just served, got %v", history["player-1"])
+	}
+}
+
+func TestUnseenPickerIsPerPlayerAndForgetsRemovedQuestions(t *testing.T) {
+	path := seenQuestionsPath
+	seenQuestionsPath = filepath.Join(t.TempDir(), "seen_questions.json")
+	defer func() { seenQuestionsPath = path }()
+
+	history := map[string][]int{"player-1": {1, 2, 99}}
+	if err := writeJSONFile(seenQuestionsPath, history); err != nil {
+		t.Fatal(err)
+	}
+
+	questions := []Question{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+	selected := pickUnseen(t, "player-1", questions, questions, 2)
+	for _, q := range selected {
+		if q.ID == 1 || q.ID == 2 {
+			t.Errorf("player-1 should not be served seen question %d", q.ID)
+		}
+	}
+
+	if selected = pickUnseen(t, "player-2", questions, questions, 4); len(selected) != 4 {
+		t.Errorf("a new player should be able to see every question, got %v", selected)
+	}
+
+	if err := readJSONFile(seenQuestionsPath, &history); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range history["player-1"] {
+		if id == 99 {
+			t.Errorf("question 99 is no longer in the bank and should be forgotten, got %v", history["player-1"])
+		}
+	}
+}
+
+func TestUnseenPickerKeepsHistoryOutsidePool(t *testing.T) {
+	path := seenQuestionsPath
+	seenQuestionsPath = filepath.Join(t.TempDir(), "seen_questions.json")
+	defer func() { seenQuestionsPath = path }()
+
+	var bank []Question
+	for i := 1; i <= 4; i++ {
+		bank = append(bank, Question{ID: i, Question: "Q?", Choices: []string{"A", "B"}})
+	}
+	first, second := bank[:2], bank[2:]
+
+	pickUnseen(t, "player-1", bank, first, 2)
+	// Exhausting the second pool twice starts a new cycle for it only
+	for round := 0; round < 2; round++ {
+		pickUnseen(t, "player-1", bank, second, 2)
+	}
+
+	history := make(map[string][]int)
+	if err := readJSONFile(seenQuestionsPath, &history); err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[int]bool)
+	for _, id := range history["player-1"] {
+		seen[id] = true
+	}
+	if !seen[1] || !seen[2] || len(seen) != 4 {
+		t.Errorf("resetting one pool should keep the other pool's history, got %v", history["player-1"])
+	}
+}
+
+func TestUnseenPickerShufflesEndOfCycle(t *testing.T) {
+	path := seenQuestionsPath
+	seenQuestionsPath = filepath.Join(t.TempDir(), "seen_questions.json")
+	defer func() { seenQuestionsPath = path }()
+
+	var bank []Question
+	for i := 1; i <= 20; i++ {
+		bank = append(bank, Question{ID: i, Question: "Q?", Choices: []string{"A", "B"}})
+	}
+	// Asking for the whole bank leaves nothing for selectRandomQuestions to
+	// choose between, but the order should still be random
+	for try := 0; try < 3; try++ {
+		selected := pickUnseen(t, "player-1", bank, bank, len(bank))
+		for i, q := range selected {
+			if q.ID != bank[i].ID {
+				return
+			}
+		}
+	}
+	t.Error("remaining unseen questions were served in bank order")
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuestionSource supplies the question bank. Load is called for every new
+// quiz, so sources that are slow to read should cache.
+type QuestionSource interface {
+	Load() ([]Question, error)
+}
+
+// FileSource loads a single question bank file
+type FileSource struct {
+	Path string
+}
+
+func (s FileSource) Load() ([]Question, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	return loadQuestionData(s.Path, data)
+}
+
+// DirSource loads a directory of bank files with namespaced IDs
+type DirSource struct {
+	Dir string
+}
+
+func (s DirSource) Load() ([]Question, error) {
+	return loadQuestionDir(s.Dir)
+}
+
+// embeddedQuestionsName is the location that selects the embedded bank, and
+// the name its warnings are logged under
+const embeddedQuestionsName = "embedded:default"
+
+//go:embed default_questions.json
+var embeddedQuestions []byte
+
+// EmbeddedSource loads the small default bank built into the binary, so the
+// server can start without a questions.json
+type EmbeddedSource struct{}
+
+func (EmbeddedSource) Load() ([]Question, error) {
+	return loadQuestionData(embeddedQuestionsName, embeddedQuestions)
+}
+
+// remoteBankMaxAge is how long a bank fetched over HTTP is used before it is
+// checked for changes
+const remoteBankMaxAge = 30 * time.Second
+
+// maxRemoteBankSize limits how much of a response is read as a bank
+const maxRemoteBankSize = 10 << 20
+
+// HTTPSource loads a question bank from a URL. Refreshes send the last ETag
+// so an unchanged bank costs a 304, and if a refresh fails or returns an
+// invalid bank the last good copy keeps being served.
+type HTTPSource struct {
+	URL    string
+	Client *http.Client
+	// MaxAge is how long a fetched bank is used before checking for changes
+	MaxAge time.Duration
+
+	mu      sync.Mutex
+	etag    string
+	last    []Question
+	fetched time.Time
+}
+
+// newHTTPSource returns an HTTPSource with the default timeout and max age
+func newHTTPSource(url string) *HTTPSource {
+	return &HTTPSource{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+		MaxAge: remoteBankMaxAge,
+	}
+}
+
+func (s *HTTPSource) Load() ([]Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.last == nil || time.Since(s.fetched) >= s.MaxAge {
+		if err := s.refresh(); err != nil {
+			if s.last == nil {
+				return nil, err
+			}
+			log.Printf("Error refreshing questions, using the last good copy: %v", err)
+		}
+	}
+	// Callers get their own slice so they cannot disturb the cached copy
+	return append([]Question(nil), s.last...), nil
+}
+
+// refresh fetches the bank if it changed since the last fetch
+func (s *HTTPSource) refresh() error {
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	if err != nil {
+		return err
+	}
+	if s.etag != "" && s.last != nil {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && s.last != nil:
+		s.fetched = time.Now()
+		return nil
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%s: %s", s.URL, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteBankSize))
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.URL, err)
+	}
+	questions, err := loadQuestionData(s.URL, data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.URL, err)
+	}
+	if len(questions) == 0 {
+		return fmt.Errorf("%s: bank has no questions", s.URL)
+	}
+
+	s.etag = resp.Header.Get("ETag")
+	s.last = questions
+	s.fetched = time.Now()
+	return nil
+}
+
+// httpSources keeps one HTTPSource per URL so ETags and last good copies
+// survive between loads
+var (
+	httpSources   = make(map[string]*HTTPSource)
+	httpSourceMux sync.Mutex
+)
+
+// questionSourceFor returns the source for a location: an http(s) URL, the
+// embedded bank, a directory of bank files, or a single bank file
+func questionSourceFor(location string) QuestionSource {
+	switch {
+	case strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://"):
+		httpSourceMux.Lock()
+		defer httpSourceMux.Unlock()
+		s, ok := httpSources[location]
+		if !ok {
+			s = newHTTPSource(location)
+			httpSources[location] = s
+		}
+		return s
+	case location == embeddedQuestionsName:
+		return EmbeddedSource{}
+	}
+	if info, err := os.Stat(location); err == nil && info.IsDir() {
+		return DirSource{location}
+	}
+	return FileSource{location}
+}
+
+// defaultQuestionSource returns the source the server loads questions from:
+// QUESTIONS_PATH if set, otherwise questions.json, falling back to the
+// embedded bank when there is no questions.json
+func defaultQuestionSource() QuestionSource {
+	if os.Getenv("QUESTIONS_PATH") == "" {
+		if _, err := os.Stat(questionBankPath()); errors.Is(err, os.ErrNotExist) {
+			return EmbeddedSource{}
+		}
+	}
+	return questionSourceFor(questionBankPath())
+}
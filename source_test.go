@@ -0,0 +1,182 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// bankServer is an httptest stand-in for a remote question bank that
+// honours If-None-Match and can be switched to failing
+type bankServer struct {
+	mu       sync.Mutex
+	body     string
+	etag     string
+	status   int
+	requests int
+	notMod   int
+}
+
+func (b *bankServer) set(body, etag string, status int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.body, b.etag, b.status = body, etag, status
+}
+
+// counts returns how many requests were served and how many got a 304
+func (b *bankServer) counts() (requests, notModified int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.requests, b.notMod
+}
+
+func (b *bankServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	if b.status != http.StatusOK {
+		http.Error(w, "unavailable", b.status)
+		return
+	}
+	if b.etag != "" && r.Header.Get("If-None-Match") == b.etag {
+		b.notMod++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", b.etag)
+	w.Write([]byte(b.body))
+}
+
+const remoteBankV1 = `{"schema_version": 2, "questions": [
+	{"id": 1, "question": "Q1?", "choices": ["A", "B"], "answer_index": 0, "explanation": "A"}
+]}`
+
+const remoteBankV2 = `{"schema_version": 2, "questions": [
+	{"id": 1, "question": "Q1?", "choices": ["A", "B"], "answer_index": 0, "explanation": "A"},
+	{"id": 2, "question": "Q2?", "choices": ["A", "B"], "answer_index": 1, "explanation": "B"}
+]}`
+
+func TestHTTPSource(t *testing.T) {
+	bank := &bankServer{}
+	bank.set(remoteBankV1, `"v1"`, http.StatusOK)
+	server := httptest.NewServer(bank)
+	defer server.Close()
+
+	source := newHTTPSource(server.URL)
+	source.MaxAge = 0
+
+	load := func(want int) {
+		t.Helper()
+		questions, err := source.Load()
+		if err != nil {
+			t.Fatalf("Load() returned error: %v", err)
+		}
+		if len(questions) != want {
+			t.Errorf("Load() returned %d questions, want %d", len(questions), want)
+		}
+	}
+
+	// An unchanged bank is revalidated with its ETag
+	load(1)
+	load(1)
+	if requests, notModified := bank.counts(); requests != 2 || notModified != 1 {
+		t.Errorf("got %d requests and %d 304s, want 2 and 1", requests, notModified)
+	}
+
+	// A changed bank is picked up
+	bank.set(remoteBankV2, `"v2"`, http.StatusOK)
+	load(2)
+
+	// Failed refreshes and invalid banks fall back to the last good copy
+	bank.set("", "", http.StatusInternalServerError)
+	load(2)
+	bank.set(`{"schema_version": 2, "questions": [{"id": 1, "question": "", "choices": ["A"]}]}`, `"bad"`, http.StatusOK)
+	load(2)
+	server.Close()
+	load(2)
+
+	// Callers cannot change the cached copy
+	questions, _ := source.Load()
+	questions[0].Question = "changed"
+	if again, _ := source.Load(); again[0].Question != "Q1?" {
+		t.Errorf("cached bank was modified through a returned slice")
+	}
+}
+
+func TestHTTPSourceMaxAge(t *testing.T) {
+	bank := &bankServer{}
+	bank.set(remoteBankV1, `"v1"`, http.StatusOK)
+	server := httptest.NewServer(bank)
+	defer server.Close()
+
+	source := newHTTPSource(server.URL)
+	for i := 0; i < 3; i++ {
+		if _, err := source.Load(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests, _ := bank.counts(); requests != 1 {
+		t.Errorf("got %d requests within the max age, want 1", requests)
+	}
+}
+
+func TestHTTPSourceInitialFailure(t *testing.T) {
+	bank := &bankServer{}
+	bank.set("", "", http.StatusNotFound)
+	server := httptest.NewServer(bank)
+	defer server.Close()
+
+	if _, err := newHTTPSource(server.URL).Load(); err == nil {
+		t.Error("Load() succeeded without ever fetching a bank")
+	}
+}
+
+func TestQuestionSourceFor(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "questions.json")
+	if err := os.WriteFile(file, []byte(remoteBankV1), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, ok := questionSourceFor(file).(FileSource); !ok || s.Path != file {
+		t.Errorf("file location gave %#v", questionSourceFor(file))
+	}
+	if s, ok := questionSourceFor(dir).(DirSource); !ok || s.Dir != dir {
+		t.Errorf("directory location gave %#v", questionSourceFor(dir))
+	}
+	if _, ok := questionSourceFor(embeddedQuestionsName).(EmbeddedSource); !ok {
+		t.Errorf("embedded location gave %#v", questionSourceFor(embeddedQuestionsName))
+	}
+	url := "https://example.com/questions.json"
+	if s, ok := questionSourceFor(url).(*HTTPSource); !ok || s != questionSourceFor(url) {
+		t.Errorf("URL location should give the same HTTPSource each time")
+	}
+}
+
+func TestEmbeddedDefaultBank(t *testing.T) {
+	questions, err := EmbeddedSource{}.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(questions) < NumQuestions {
+		t.Errorf("embedded bank has %d questions, want at least %d", len(questions), NumQuestions)
+	}
+	if issues := lintQuestions(questions); len(issues) != 0 {
+		t.Errorf("embedded bank has lint issues: %v", issues)
+	}
+
+	// The server falls back to it when there is no questions.json
+	if _, err := os.Stat("questions.json"); err == nil {
+		t.Skip("questions.json exists in the working directory")
+	}
+	if _, ok := defaultQuestionSource().(EmbeddedSource); !ok {
+		t.Errorf("without questions.json the source is %#v, want EmbeddedSource", defaultQuestionSource())
+	}
+	t.Setenv("QUESTIONS_PATH", "questions.json")
+	if _, ok := defaultQuestionSource().(FileSource); !ok {
+		t.Errorf("an explicit QUESTIONS_PATH should not fall back to the embedded bank")
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// studyStatePath is the file per-player spaced repetition state is kept in
+var studyStatePath = "study_state.json"
+
+// studyMux serializes read-modify-write cycles on the study state file
+var studyMux sync.Mutex
+
+// minEaseFactor is the lowest ease factor SM-2 allows, so hard questions
+// still get spaced out eventually
+const minEaseFactor = 1.3
+
+// ReviewState is a player's SM-2 scheduling state for one question
+type ReviewState struct {
+	EaseFactor   float64   `json:"ease_factor"`
+	IntervalDays int       `json:"interval_days"`
+	Repetitions  int       `json:"repetitions"`
+	Lapses       int       `json:"lapses"`
+	LastQuality  int       `json:"last_quality"`
+	Due          time.Time `json:"due"`
+	LastReviewed time.Time `json:"last_reviewed"`
+}
+
+// studyState maps player IDs to their review state keyed by question ID
+type studyState map[string]map[int]ReviewState
+
+// reviewQuality converts an answer into an SM-2 quality grade from 0 to 5.
+// Stated confidence refines the grade: a confident correct answer is an easy
+// recall and a confident wrong one a complete blackout.
+func reviewQuality(correct bool, confidence Confidence) int {
+	if !correct {
+		if confidence == ConfidenceHigh {
+			return 0
+		}
+		return 1
+	}
+	switch confidence {
+	case ConfidenceLow:
+		return 3
+	case ConfidenceHigh:
+		return 5
+	default:
+		return 4
+	}
+}
+
+// review applies the SM-2 algorithm to a question's state after an answer of
+// the given quality at time now
+func (rs ReviewState) review(quality int, now time.Time) ReviewState {
+	if rs.EaseFactor == 0 {
+		rs.EaseFactor = 2.5
+	}
+
+	if quality >= 3 {
+		switch rs.Repetitions {
+		case 0:
+			rs.IntervalDays = 1
+		case 1:
+			rs.IntervalDays = 6
+		default:
+			rs.IntervalDays = int(math.Round(float64(rs.IntervalDays) * rs.EaseFactor))
+		}
+		rs.Repetitions++
+	} else {
+		rs.Repetitions = 0
+		rs.IntervalDays = 1
+		rs.Lapses++
+	}
+
+	miss := float64(5 - quality)
+	rs.EaseFactor += 0.1 - miss*(0.08+miss*0.02)
+	if rs.EaseFactor < minEaseFactor {
+		rs.EaseFactor = minEaseFactor
+	}
+
+	rs.LastQuality = quality
+	rs.LastReviewed = now
+	rs.Due = now.AddDate(0, 0, rs.IntervalDays)
+	return rs
+}
+
+// loadReviewStates returns the saved review state for a player
+func loadReviewStates(player string) (map[int]ReviewState, error) {
+	studyMux.Lock()
+	defer studyMux.Unlock()
+
+	state := studyState{}
+	if err := readJSONFile(studyStatePath, &state); err != nil {
+		return nil, err
+	}
+	return state[player], nil
+}
+
+// recordReview updates and saves a player's review state for a question
+func recordReview(player string, questionID, quality int, now time.Time) error {
+	studyMux.Lock()
+	defer studyMux.Unlock()
+
+	state := studyState{}
+	if err := readJSONFile(studyStatePath, &state); err != nil {
+		return err
+	}
+	if state[player] == nil {
+		state[player] = make(map[int]ReviewState)
+	}
+	state[player][questionID] = state[player][questionID].review(quality, now)
+	return writeJSONFile(studyStatePath, state)
+}
+
+// selectStudyQuestions picks n questions for a study session. Questions the
+// player last missed come first, then other due questions, then questions
+// they have never seen in random order, and finally questions that are not
+// yet due. Within each group the earliest due date comes first.
+func selectStudyQuestions(questions []Question, states map[int]ReviewState, n int, now time.Time) []Question {
+	var missed, due, unseen, later []Question
+	for _, q := range questions {
+		rs, seen := states[q.ID]
+		switch {
+		case !seen:
+			unseen = append(unseen, q)
+		case rs.LastQuality < 3:
+			missed = append(missed, q)
+		case rs.Due.After(now):
+			later = append(later, q)
+		default:
+			due = append(due, q)
+		}
+	}
+
+	byDue := func(qs []Question) {
+		sort.SliceStable(qs, func(i, j int) bool {
+			return states[qs[i].ID].Due.Before(states[qs[j].ID].Due)
+		})
+	}
+	byDue(missed)
+	byDue(due)
+	byDue(later)
+
+	r := rand.New(rand.NewSource(now.UnixNano()))
+	r.Shuffle(len(unseen), func(i, j int) {
+		unseen[i], unseen[j] = unseen[j], unseen[i]
+	})
+
+	selected := append(append(append(missed, due...), unseen...), later...)
+	if len(selected) > n {
+		selected = selected[:n]
+	}
+	return selected
+}
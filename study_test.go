@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReviewStateSM2(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	rs := ReviewState{}.review(4, now)
+	if rs.IntervalDays != 1 || rs.Repetitions != 1 || rs.EaseFactor != 2.5 {
+		t.Errorf("first correct review = %+v, want 1 day interval and unchanged ease", rs)
+	}
+
+	rs = rs.review(4, now)
+	if rs.IntervalDays != 6 {
+		t.Errorf("second correct review interval = %d, want 6", rs.IntervalDays)
+	}
+
+	rs = rs.review(5, now)
+	if rs.IntervalDays != 15 || rs.EaseFactor <= 2.5 {
+		t.Errorf("third review = %+v, want 15 day interval and higher ease", rs)
+	}
+
+	rs = rs.review(1, now)
+	if rs.IntervalDays != 1 || rs.Repetitions != 0 || rs.Lapses != 1 {
+		t.Errorf("missed review = %+v, want reset to 1 day with a lapse", rs)
+	}
+	if !rs.Due.Equal(now.AddDate(0, 0, 1)) {
+		t.Errorf("missed review due = %v, want tomorrow", rs.Due)
+	}
+
+	for i := 0; i < 10; i++ {
+		rs = rs.review(0, now)
+	}
+	if rs.EaseFactor != minEaseFactor {
+		t.Errorf("ease factor = %v, want floor of %v", rs.EaseFactor, minEaseFactor)
+	}
+}
+
+func TestSelectStudyQuestionsPriority(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	questions := []Question{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}}
+	states := map[int]ReviewState{
+		1: {LastQuality: 4, Due: now.AddDate(0, 0, 5)},
+		2: {LastQuality: 4, Due: now.AddDate(0, 0, -1)},
+		3: {LastQuality: 1, Due: now.AddDate(0, 0, 1)},
+		4: {LastQuality: 5, Due: now.AddDate(0, 0, -3)},
+	}
+
+	selected := selectStudyQuestions(questions, states, 4, now)
+	var ids []int
+	for _, q := range selected {
+		ids = append(ids, q.ID)
+	}
+	want := []int{3, 4, 2, 5}
+	if len(ids) != len(want) {
+		t.Fatalf("selectStudyQuestions() = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("selectStudyQuestions() = %v, want %v", ids, want)
+		}
+	}
+}
+
+func TestStudyModePersistsReviewsPerPlayer(t *testing.T) {
+	path := studyStatePath
+	studyStatePath = filepath.Join(t.TempDir(), "study_state.json")
+	defer func() { studyStatePath = path }()
+
+	questionsJSON := `[
+		{"id": 1, "question": "Q1?", "choices": ["A", "B"], "answer_index": 0},
+		{"id": 2, "question": "Q2?", "choices": ["A", "B"], "answer_index": 0},
+		{"id": 3, "question": "Q3?", "choices": ["A", "B"], "answer_index": 0},
+		{"id": 4, "question": "Q4?", "choices": ["A", "B"], "answer_index": 0}
+	]`
+	if err := os.WriteFile("questions.json", []byte(questionsJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("questions.json")
+	if err := os.WriteFile("quiz.json", []byte(`{"title": "Study", "spaced_repetition": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.json")
+	if err := os.WriteFile("quiz.html", []byte(`{{.SessionID}}|{{.Question.Question}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("quiz.html")
+
+	cookie := &http.Cookie{Name: playerCookieName, Value: "learner-1"}
+	startQuiz := func() *QuizSession {
+		req := httptest.NewRequest(http.MethodGet, "/quiz", nil)
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		quizHandler(rr, req)
+		id := strings.SplitN(rr.Body.String(), "|", 2)[0]
+
+		sessionMux.RLock()
+		defer sessionMux.RUnlock()
+		session := sessions[id]
+		if session == nil {
+			t.Fatalf("quizHandler did not start a session: %q", rr.Body.String())
+		}
+		return session
+	}
+
+	first := startQuiz()
+	if first.PlayerID != "learner-1" || !first.Study {
+		t.Fatalf("session should be a study session for the cookie's player, got %+v", first)
+	}
+	missedID := first.Questions[0].ID
+	postAnswer(first.ID, 0, 1)
+	for i := 1; i < len(first.Questions); i++ {
+		postAnswer(first.ID, i, 0)
+	}
+
+	states, err := loadReviewStates("learner-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != NumQuestions || states[missedID].Lapses != 1 {
+		t.Errorf("review state should be saved for every answered question, got %+v", states)
+	}
+
+	second := startQuiz()
+	if second.Questions[0].ID != missedID {
+		t.Errorf("returning learner should see missed question %d first, got %d", missedID, second.Questions[0].ID)
+	}
+
+	if other, _ := loadReviewStates("learner-2"); len(other) != 0 {
+		t.Errorf("review state leaked to another player: %+v", other)
+	}
+}
+
+func TestPlayerIDIssuesCookie(t *testing.T) {
+	rr := httptest.NewRecorder()
+	id := playerID(rr, httptest.NewRequest(http.MethodGet, "/quiz", nil))
+	if id == "" {
+		t.Fatal("playerID() returned an empty id")
+	}
+	if !strings.Contains(rr.Header().Get("Set-Cookie"), playerCookieName+"="+id) {
+		t.Errorf("playerID() should set the player cookie, got %q", rr.Header().Get("Set-Cookie"))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/quiz", nil)
+	req.AddCookie(&http.Cookie{Name: playerCookieName, Value: id})
+	rr = httptest.NewRecorder()
+	if got := playerID(rr, req); got != id || rr.Header().Get("Set-Cookie") != "" {
+		t.Errorf("playerID() should reuse the existing cookie, got %q", got)
+	}
+}
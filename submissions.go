@@ -0,0 +1,400 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// submissionsPath is the file player-submitted questions are kept in until
+// and after they are moderated
+var submissionsPath = "submissions.json"
+
+// submissionsMux serializes read-modify-write cycles on the submissions file
+var submissionsMux sync.Mutex
+
+// SubmissionState is where a submitted question is in moderation
+type SubmissionState string
+
+const (
+	// SubmissionPending is waiting for an admin
+	SubmissionPending SubmissionState = "pending"
+	// SubmissionApproved has been added to the question bank
+	SubmissionApproved SubmissionState = "approved"
+	// SubmissionRejected was turned down
+	SubmissionRejected SubmissionState = "rejected"
+)
+
+// maxPendingSubmissions limits how many questions one player can have
+// waiting for moderation
+const maxPendingSubmissions = 5
+
+// maxAuthorLength limits the name a question is credited to
+const maxAuthorLength = 80
+
+// Submission is a question proposed by a player
+type Submission struct {
+	ID        string          `json:"id"`
+	PlayerID  string          `json:"player_id"`
+	Author    string          `json:"author"`
+	Submitted time.Time       `json:"submitted"`
+	State     SubmissionState `json:"state"`
+	Question  Question        `json:"question"`
+
+	// Reviewer, Reviewed and Note record the moderation decision, and
+	// QuestionID the bank ID an approved question was given
+	Reviewer   string    `json:"reviewer,omitempty"`
+	Reviewed   time.Time `json:"reviewed"`
+	Note       string    `json:"note,omitempty"`
+	QuestionID int       `json:"question_id,omitempty"`
+}
+
+// errNotPending is returned when a submission has already been moderated
+var errNotPending = errors.New("submission has already been reviewed")
+
+// loadSubmissions returns every submission, oldest first
+func loadSubmissions() ([]Submission, error) {
+	submissionsMux.Lock()
+	defer submissionsMux.Unlock()
+
+	var submissions []Submission
+	if err := readJSONFile(submissionsPath, &submissions); err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
+
+// updateSubmissions applies update to the stored submissions and saves the
+// result unless update fails
+func updateSubmissions(update func([]Submission) ([]Submission, error)) error {
+	submissionsMux.Lock()
+	defer submissionsMux.Unlock()
+
+	var submissions []Submission
+	if err := readJSONFile(submissionsPath, &submissions); err != nil {
+		return err
+	}
+	submissions, err := update(submissions)
+	if err != nil {
+		return err
+	}
+	return writeJSONFile(submissionsPath, submissions)
+}
+
+// submittedQuestion converts a player's form to a question, checking it with
+// the same rules as loading the bank. Players can set the text, choices,
+// answer, explanation, hint and tags; the rest is left to moderators.
+func submittedQuestion(r *http.Request) (Question, []string) {
+	form := editPageFromForm(r)
+	page := questionEditPage{
+		Text:        form.Text,
+		Choices:     form.Choices,
+		AnswerIndex: form.AnswerIndex,
+		Explanation: form.Explanation,
+		Hint:        form.Hint,
+		Tags:        form.Tags,
+	}
+	q, errs := page.question()
+	if len(errs) > 0 {
+		return q, errs
+	}
+	return q, issueErrors(lintQuestion(q))
+}
+
+// issueErrors returns the messages of the error-severity issues
+func issueErrors(issues []lintIssue) []string {
+	var errs []string
+	for _, issue := range issues {
+		if issue.Severity == severityError {
+			errs = append(errs, issue.Message)
+		}
+	}
+	return errs
+}
+
+// submitPage is the template data for the question submission form
+type submitPage struct {
+	Author string
+	Form   questionEditPage
+	Errors []string
+	Sent   bool
+	// Submissions lists the player's own submissions, newest first, so they
+	// can follow moderation
+	Submissions []Submission
+}
+
+// newSubmitPage returns the form with the player's submissions
+func newSubmitPage(player string) (submitPage, error) {
+	submissions, err := loadSubmissions()
+	if err != nil {
+		return submitPage{}, err
+	}
+	var page submitPage
+	for i := len(submissions) - 1; i >= 0; i-- {
+		if submissions[i].PlayerID == player {
+			page.Submissions = append(page.Submissions, submissions[i])
+		}
+	}
+	return page, nil
+}
+
+// submitHandler handles /submit. GET shows the form for proposing a question
+// along with the player's earlier submissions; POST checks the question and
+// queues it for moderation.
+func submitHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	player := playerID(w, r)
+
+	page, err := newSubmitPage(player)
+	if err != nil {
+		log.Printf("Error loading submissions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if r.Method == http.MethodGet {
+		page.Sent = r.URL.Query().Get("sent") != ""
+		renderPage(w, "submit.html", page)
+		return
+	}
+
+	page.Author = strings.TrimSpace(r.FormValue("author"))
+	page.Form = editPageFromForm(r)
+	q, errs := submittedQuestion(r)
+	switch n := utf8.RuneCountInString(page.Author); {
+	case n == 0:
+		errs = append(errs, "enter the name to credit the question to")
+	case n > maxAuthorLength:
+		errs = append(errs, fmt.Sprintf("name is %d characters (limit %d)", n, maxAuthorLength))
+	}
+	if len(errs) > 0 {
+		page.Errors = errs
+		renderPageStatus(w, http.StatusUnprocessableEntity, "submit.html", page)
+		return
+	}
+
+	err = updateSubmissions(func(submissions []Submission) ([]Submission, error) {
+		pending := 0
+		for _, s := range submissions {
+			if s.PlayerID == player && s.State == SubmissionPending {
+				pending++
+			}
+		}
+		if pending >= maxPendingSubmissions {
+			return nil, rejectedEdit(fmt.Sprintf("you already have %d questions waiting for review", pending))
+		}
+		return append(submissions, Submission{
+			ID:        generateSessionID(),
+			PlayerID:  player,
+			Author:    page.Author,
+			Submitted: time.Now(),
+			State:     SubmissionPending,
+			Question:  q,
+		}), nil
+	})
+	var rejected rejectedEdit
+	switch {
+	case errors.As(err, &rejected):
+		page.Errors = []string{rejected.Error()}
+		renderPageStatus(w, http.StatusTooManyRequests, "submit.html", page)
+		return
+	case err != nil:
+		log.Printf("Error saving submission: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/submit?sent=1", http.StatusSeeOther)
+}
+
+// submissionListPage is the template data for the moderation queue
+type submissionListPage struct {
+	State       string
+	Submissions []Submission
+	Errors      []string
+	// Token authorizes the review forms
+	Token string
+}
+
+// newSubmissionListPage lists the submissions in a state, or all of them for
+// "all", oldest first so the queue is worked in order
+func newSubmissionListPage(state string) (submissionListPage, error) {
+	if state == "" {
+		state = string(SubmissionPending)
+	}
+	submissions, err := loadSubmissions()
+	if err != nil {
+		return submissionListPage{}, err
+	}
+	page := submissionListPage{State: state}
+	for _, s := range submissions {
+		if state == "all" || string(s.State) == state {
+			page.Submissions = append(page.Submissions, s)
+		}
+	}
+	sort.SliceStable(page.Submissions, func(i, j int) bool {
+		return page.Submissions[i].Submitted.Before(page.Submissions[j].Submitted)
+	})
+	return page, nil
+}
+
+// adminSubmissionsHandler handles GET /admin/submissions, listing the
+// submissions in the given "state" (default pending, or all)
+func adminSubmissionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	page, err := newSubmissionListPage(r.URL.Query().Get("state"))
+	if err != nil {
+		log.Printf("Error loading submissions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	page.Token = adminFormToken(r, "/admin/submissions/review")
+	renderPage(w, "admin_submissions.html", page)
+}
+
+// reviewSubmission applies a moderation action to the pending submission
+// with the given ID. "edit" replaces its question with edited, "approve"
+// does the same and adds the question to the bank credited to its author,
+// and "reject" closes it. It returns the bank issues when approval fails the
+// load checks.
+func reviewSubmission(id, action string, edited *Question, reviewer, note string, now time.Time) (Submission, []lintIssue, error) {
+	var result Submission
+	var issues []lintIssue
+	err := updateSubmissions(func(submissions []Submission) ([]Submission, error) {
+		i := -1
+		for j := range submissions {
+			if submissions[j].ID == id {
+				i = j
+			}
+		}
+		if i < 0 {
+			return nil, rejectedEdit(fmt.Sprintf("submission %s not found", id))
+		}
+		s := &submissions[i]
+		if s.State != SubmissionPending {
+			return nil, errNotPending
+		}
+		if edited != nil {
+			s.Question = *edited
+		}
+
+		switch action {
+		case "edit":
+			result = *s
+			return submissions, nil
+		case "approve":
+			var err error
+			issues, err = editQuestionBank(func(questions []Question) ([]Question, error) {
+				// An earlier approval may have reached the bank without
+				// the submission being saved as approved, so adding the
+				// question again would duplicate it
+				q := s.Question
+				for _, existing := range questions {
+					if existing.Submission == s.ID {
+						s.QuestionID = existing.ID
+						return nil, errUnchanged
+					}
+				}
+				q.ID, q.Version, q.Retired = 0, 0, false
+				q.Author, q.Submission = s.Author, s.ID
+				for _, existing := range questions {
+					if existing.ID > q.ID {
+						q.ID = existing.ID
+					}
+				}
+				q.ID++
+				s.QuestionID = q.ID
+				return append(questions, q), nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			s.State = SubmissionApproved
+		case "reject":
+			s.State = SubmissionRejected
+		default:
+			return nil, rejectedEdit(fmt.Sprintf("unknown action %q", action))
+		}
+		s.Reviewer, s.Reviewed, s.Note = reviewer, now, note
+		result = *s
+		return submissions, nil
+	})
+	return result, issues, err
+}
+
+// adminSubmissionReviewHandler handles POST /admin/submissions/review. The
+// form names the submission "id" and the "action" (edit, approve or reject),
+// with an optional "note" for the author. When it includes the question
+// fields of the editor, they replace the submitted question before an edit
+// or approval.
+func adminSubmissionReviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var edited *Question
+	var errs []string
+	if r.FormValue("question") != "" {
+		form := editPageFromForm(r)
+		form.ID = ""
+		q, parseErrs := form.question()
+		errs = parseErrs
+		if len(errs) == 0 {
+			errs = issueErrors(lintQuestion(q))
+		}
+		edited = &q
+	}
+
+	admin, _, _ := r.BasicAuth()
+	var sub Submission
+	var issues []lintIssue
+	var err error
+	if len(errs) == 0 {
+		sub, issues, err = reviewSubmission(r.FormValue("id"), r.FormValue("action"), edited, admin, strings.TrimSpace(r.FormValue("note")), time.Now())
+	}
+
+	var rejected rejectedEdit
+	switch {
+	case len(errs) > 0:
+	case err == nil:
+		log.Printf("Submission %s %s by %s", sub.ID, sub.State, admin)
+		http.Redirect(w, r, "/admin/submissions", http.StatusSeeOther)
+		return
+	case errors.Is(err, errNotPending):
+		http.Error(w, "Submission has already been reviewed", http.StatusConflict)
+		return
+	case errors.Is(err, errInvalidBank):
+		for _, issue := range issues {
+			if issue.Severity == severityError {
+				errs = append(errs, issue.String())
+			}
+		}
+	case errors.As(err, &rejected):
+		errs = []string{rejected.Error()}
+	default:
+		log.Printf("Error reviewing submission: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	page, err := newSubmissionListPage(string(SubmissionPending))
+	if err != nil {
+		log.Printf("Error loading submissions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	page.Errors = errs
+	page.Token = adminFormToken(r, "/admin/submissions/review")
+	renderPageStatus(w, http.StatusUnprocessableEntity, "admin_submissions.html", page)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTempSubmissions points the submissions file at a fresh directory and
+// writes stub templates for one test
+func useTempSubmissions(t *testing.T) {
+	path := submissionsPath
+	submissionsPath = filepath.Join(t.TempDir(), "submissions.json")
+	t.Cleanup(func() { submissionsPath = path })
+
+	templates := map[string]string{
+		"submit.html":            `{{range .Errors}}{{.}};{{end}}{{range .Submissions}}{{.Question.Question}}={{.State}};{{end}}`,
+		"admin_submissions.html": `{{range .Errors}}{{.}};{{end}}{{range .Submissions}}{{.ID}}:{{.Author}}:{{.Question.Question}};{{end}}`,
+	}
+	for name, body := range templates {
+		name := name
+		if err := os.WriteFile(name, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.Remove(name) })
+	}
+}
+
+// submit posts a question proposal as the given player
+func submit(player string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: playerCookieName, Value: player})
+	rr := httptest.NewRecorder()
+	submitHandler(rr, req)
+	return rr
+}
+
+func proposal(text string) url.Values {
+	return url.Values{
+		"author":       {"Ada"},
+		"question":     {text},
+		"choices":      {"Yes\nNo"},
+		"answer_index": {"0"},
+		"explanation":  {"Because."},
+		// Players cannot set these
+		"difficulty": {"3"},
+		"template":   {`{"answer": "1"}`},
+	}
+}
+
+func TestSubmitQuestion(t *testing.T) {
+	useTempSubmissions(t)
+
+	if rr := submit("p1", proposal("Is water wet?")); rr.Code != http.StatusSeeOther {
+		t.Fatalf("submit: got %d %q", rr.Code, rr.Body.String())
+	}
+	submissions, err := loadSubmissions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(submissions) != 1 {
+		t.Fatalf("got %d submissions, want 1", len(submissions))
+	}
+	s := submissions[0]
+	if s.PlayerID != "p1" || s.Author != "Ada" || s.State != SubmissionPending || s.Question.Difficulty != 0 || s.Question.Template != nil {
+		t.Errorf("submission = %+v", s)
+	}
+
+	// The same checks as loading the bank apply
+	bad := proposal("Is water wet?")
+	bad.Set("choices", "Yes\nyes")
+	bad.Set("author", "")
+	rr := submit("p1", bad)
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "repeats choice 0") || !strings.Contains(rr.Body.String(), "enter the name") {
+		t.Errorf("invalid submission: got %d %q", rr.Code, rr.Body.String())
+	}
+
+	// Players see their own submissions and are limited in how many wait
+	for i := 1; i < maxPendingSubmissions; i++ {
+		submit("p1", proposal("Another?"))
+	}
+	if rr := submit("p1", proposal("One too many?")); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("over the limit: got %d", rr.Code)
+	}
+	if rr := submit("p2", proposal("Someone else?")); rr.Code != http.StatusSeeOther {
+		t.Errorf("other player: got %d", rr.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/submit", nil)
+	req.AddCookie(&http.Cookie{Name: playerCookieName, Value: "p2"})
+	rr = httptest.NewRecorder()
+	submitHandler(rr, req)
+	if rr.Body.String() != "Someone else?=pending;" {
+		t.Errorf("p2's submissions = %q", rr.Body.String())
+	}
+}
+
+func TestReviewSubmissions(t *testing.T) {
+	bank := useTempBank(t, adminTestBank)
+	useTempSubmissions(t)
+	for _, text := range []string{"First?", "Second?", "Third?"} {
+		if rr := submit("p1", proposal(text)); rr.Code != http.StatusSeeOther {
+			t.Fatalf("submit: got %d %q", rr.Code, rr.Body.String())
+		}
+	}
+	submissions, _ := loadSubmissions()
+
+	review := func(form url.Values) *httptest.ResponseRecorder {
+		return adminPost(adminSubmissionReviewHandler, "/admin/submissions/review", form)
+	}
+
+	// Approving adds the question to the bank with attribution
+	if rr := review(url.Values{"id": {submissions[0].ID}, "action": {"approve"}}); rr.Code != http.StatusSeeOther {
+		t.Fatalf("approve: got %d %q", rr.Code, rr.Body.String())
+	}
+	questions, err := readQuestionBank(bank)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := questions[len(questions)-1]; len(questions) != 3 || last.ID != 3 || last.Author != "Ada" || last.Question != "First?" {
+		t.Errorf("bank after approval = %+v", questions)
+	}
+
+	// Edits are checked and can be made before approving
+	edit := url.Values{"id": {submissions[1].ID}, "action": {"edit"}, "question": {"Second, edited?"}, "choices": {"A"}}
+	if rr := review(edit); rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "need at least 2") {
+		t.Errorf("invalid edit: got %d %q", rr.Code, rr.Body.String())
+	}
+	edit.Set("choices", "A\nB")
+	if rr := review(edit); rr.Code != http.StatusSeeOther {
+		t.Errorf("edit: got %d %q", rr.Code, rr.Body.String())
+	}
+
+	if rr := review(url.Values{"id": {submissions[2].ID}, "action": {"reject"}, "note": {"Duplicate"}}); rr.Code != http.StatusSeeOther {
+		t.Errorf("reject: got %d", rr.Code)
+	}
+	if rr := review(url.Values{"id": {submissions[2].ID}, "action": {"approve"}}); rr.Code != http.StatusConflict {
+		t.Errorf("approving a rejected submission: got %d", rr.Code)
+	}
+	if rr := review(url.Values{"id": {"nope"}, "action": {"approve"}}); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unknown submission: got %d", rr.Code)
+	}
+
+	submissions, _ = loadSubmissions()
+	got := []SubmissionState{submissions[0].State, submissions[1].State, submissions[2].State}
+	if got[0] != SubmissionApproved || got[1] != SubmissionPending || got[2] != SubmissionRejected {
+		t.Errorf("states = %v", got)
+	}
+	if submissions[0].QuestionID != 3 || submissions[0].Reviewer != "admin" || submissions[1].Question.Question != "Second, edited?" || submissions[2].Note != "Duplicate" {
+		t.Errorf("submissions = %+v", submissions)
+	}
+
+	rr := httptest.NewRecorder()
+	adminSubmissionsHandler(rr, httptest.NewRequest(http.MethodGet, "/admin/submissions", nil))
+	if want := submissions[1].ID + ":Ada:Second, edited?;"; rr.Body.String() != want {
+		t.Errorf("queue = %q, want %q", rr.Body.String(), want)
+	}
+}
+
+func TestApproveSubmissionTwice(t *testing.T) {
+	bank := useTempBank(t, adminTestBank)
+	useTempSubmissions(t)
+	if rr := submit("p1", proposal("Retried?")); rr.Code != http.StatusSeeOther {
+		t.Fatalf("submit: got %d %q", rr.Code, rr.Body.String())
+	}
+	submissions, _ := loadSubmissions()
+	id := submissions[0].ID
+
+	// An approval whose question reached the bank but whose submission was
+	// not saved as approved leaves it pending; approving again must not add
+	// a second copy
+	if _, _, err := reviewSubmission(id, "approve", nil, "admin", "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJSONFile(submissionsPath, submissions); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.ReadFile(bank)
+	sub, _, err := reviewSubmission(id, "approve", nil, "admin", "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	questions, err := readQuestionBank(bank)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(questions) != 3 || sub.State != SubmissionApproved || sub.QuestionID != 3 {
+		t.Errorf("second approval: %d questions, submission %+v", len(questions), sub)
+	}
+	if after, _ := os.ReadFile(bank); string(after) != string(before) {
+		t.Error("second approval rewrote the bank")
+	}
+}
+
+func TestApproveResubmittedQuestion(t *testing.T) {
+	bank := useTempBank(t, adminTestBank)
+	useTempSubmissions(t)
+
+	// The same name and stem with corrected choices is a new question
+	resubmitted := proposal("Same stem?")
+	resubmitted.Set("choices", "Yes\nMaybe")
+	for _, form := range []url.Values{proposal("Same stem?"), resubmitted} {
+		if rr := submit("p1", form); rr.Code != http.StatusSeeOther {
+			t.Fatalf("submit: got %d %q", rr.Code, rr.Body.String())
+		}
+	}
+	submissions, _ := loadSubmissions()
+	for _, s := range submissions {
+		if _, _, err := reviewSubmission(s.ID, "approve", nil, "admin", "", time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	questions, err := readQuestionBank(bank)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(questions) != 4 || questions[2].Submission != submissions[0].ID || questions[3].Submission != submissions[1].ID || questions[3].Choices[1] != "Maybe" {
+		t.Errorf("bank after approving both = %+v", questions)
+	}
+}
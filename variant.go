@@ -0,0 +1,609 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// QuestionTemplate turns a question into a generator of variants. Each
+// variable is drawn from a range or list, the answer and distractors are
+// expressions over the variables, and {expression} placeholders in the
+// question, explanation and hint are filled in with the drawn values.
+type QuestionTemplate struct {
+	Variables   map[string]TemplateVariable `json:"variables"`
+	Answer      string                      `json:"answer"`
+	Distractors []string                    `json:"distractors"`
+	// Decimals rounds the answer and distractors; unset shows them exactly
+	Decimals *int `json:"decimals,omitempty"`
+	// Unit is appended to every choice, as in "1500 m"
+	Unit string `json:"unit,omitempty"`
+}
+
+// TemplateVariable is either a range from Min to Max in steps of Step
+// (default 1) or a list of Values
+type TemplateVariable struct {
+	Min    *float64  `json:"min,omitempty"`
+	Max    *float64  `json:"max,omitempty"`
+	Step   float64   `json:"step,omitempty"`
+	Values []float64 `json:"values,omitempty"`
+}
+
+// maxVariableSteps caps how many values a range variable can take, keeping
+// the number of steps within what rand.Intn accepts
+const maxVariableSteps = math.MaxInt32
+
+// maxVariantDraws is how many times variables are redrawn looking for a
+// variant whose choices are all different and computable
+const maxVariantDraws = 50
+
+// placeholderPattern matches {expression} placeholders in template text
+var placeholderPattern = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// variableNamePattern restricts template variable names
+var variableNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// validate checks the template's variables and expressions
+func (t *QuestionTemplate) validate(q Question) error {
+	if len(q.Choices) > 0 {
+		return errors.New("template questions generate their choices; remove \"choices\"")
+	}
+	if len(t.Distractors) == 0 {
+		return errors.New("template needs at least one distractor")
+	}
+
+	known := map[string]bool{"answer": true}
+	for name, v := range t.Variables {
+		if !variableNamePattern.MatchString(name) || name == "answer" || templateFuncs[name] != nil {
+			return fmt.Errorf("variable name %q must be lowercase letters, digits and underscores and not \"answer\" or a function name", name)
+		}
+		if err := v.validate(); err != nil {
+			return fmt.Errorf("variable %q: %w", name, err)
+		}
+		known[name] = true
+	}
+	if t.Decimals != nil && (*t.Decimals < 0 || *t.Decimals > 10) {
+		return fmt.Errorf("decimals %d must be between 0 and 10", *t.Decimals)
+	}
+
+	check := func(what, src string, allowAnswer bool) error {
+		e, err := parseExpr(src)
+		if err != nil {
+			return fmt.Errorf("%s %q: %w", what, src, err)
+		}
+		for _, name := range e.names {
+			if !known[name] || (name == "answer" && !allowAnswer) {
+				return fmt.Errorf("%s %q: unknown variable %q", what, src, name)
+			}
+		}
+		return nil
+	}
+	if err := check("answer", t.Answer, false); err != nil {
+		return err
+	}
+	for _, d := range t.Distractors {
+		if err := check("distractor", d, true); err != nil {
+			return err
+		}
+	}
+	for _, text := range []string{q.Question, q.Explanation, q.Hint} {
+		for _, m := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			if err := check("placeholder", m[1], true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks that the variable has a usable range or list
+func (v TemplateVariable) validate() error {
+	switch {
+	case len(v.Values) > 0 && (v.Min != nil || v.Max != nil):
+		return errors.New("use either min and max or values, not both")
+	case len(v.Values) > 0:
+		return nil
+	case v.Min == nil || v.Max == nil:
+		return errors.New("needs min and max, or values")
+	case *v.Max < *v.Min:
+		return fmt.Errorf("max %g is below min %g", *v.Max, *v.Min)
+	case v.Step < 0:
+		return fmt.Errorf("step %g must be positive", v.Step)
+	case (*v.Max-*v.Min)/v.step() > maxVariableSteps:
+		return fmt.Errorf("min %g to max %g in steps of %g is more than %d steps; use a larger step", *v.Min, *v.Max, v.step(), maxVariableSteps)
+	}
+	return nil
+}
+
+// step returns the variable's step, defaulting to 1
+func (v TemplateVariable) step() float64 {
+	if v.Step == 0 {
+		return 1
+	}
+	return v.Step
+}
+
+// draw picks a value for the variable
+func (v TemplateVariable) draw(r *rand.Rand) float64 {
+	if len(v.Values) > 0 {
+		return v.Values[r.Intn(len(v.Values))]
+	}
+	step := v.step()
+	steps := int(math.Floor((*v.Max-*v.Min)/step + 1e-9))
+	return roundTo(*v.Min+float64(r.Intn(steps+1))*step, 9)
+}
+
+// variantSeed derives the seed for one question's variant from the session
+// seed, so each question in a session gets its own numbers
+func variantSeed(sessionSeed int64, questionID int) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d:%d", sessionSeed, questionID)
+	return int64(h.Sum64() & math.MaxInt64)
+}
+
+// newSessionSeed returns a seed for a new session's template variants
+func newSessionSeed() int64 {
+	return rand.New(rand.NewSource(time.Now().UnixNano())).Int63()
+}
+
+// generateVariant returns the variant of a template question for a seed. The
+// same question and seed always give the same variant, with the choices in
+// the same order.
+func generateVariant(q Question, seed int64) (Question, error) {
+	t := q.Template
+	r := rand.New(rand.NewSource(seed))
+
+	names := make([]string, 0, len(t.Variables))
+	for name := range t.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lastErr error
+	for draw := 0; draw < maxVariantDraws; draw++ {
+		vars := make(map[string]float64, len(names)+1)
+		for _, name := range names {
+			vars[name] = t.Variables[name].draw(r)
+		}
+		variant, err := t.fill(q, vars)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		// Shuffle so the answer is not always first
+		order := r.Perm(len(variant.Choices))
+		choices := make([]string, len(order))
+		for i, from := range order {
+			choices[i] = variant.Choices[from]
+			if from == 0 {
+				variant.AnswerIndex = i
+			}
+		}
+		variant.Choices = choices
+		variant.VariantSeed = seed
+		return variant, nil
+	}
+	return q, fmt.Errorf("no usable variant in %d draws: %w", maxVariantDraws, lastErr)
+}
+
+// fill builds a variant from drawn variable values, with the answer as the
+// first choice. It fails if an expression cannot be computed or two choices
+// come out the same.
+func (t *QuestionTemplate) fill(q Question, vars map[string]float64) (Question, error) {
+	answer, err := evalExpr(t.Answer, vars)
+	if err != nil {
+		return q, fmt.Errorf("answer: %w", err)
+	}
+	vars["answer"] = answer
+
+	format := func(v float64) string {
+		if t.Decimals != nil {
+			return strconv.FormatFloat(v, 'f', *t.Decimals, 64)
+		}
+		return formatNumber(v)
+	}
+	choice := func(v float64) string {
+		if t.Unit != "" {
+			return format(v) + " " + t.Unit
+		}
+		return format(v)
+	}
+
+	variant := q
+	variant.Template = nil
+	variant.Choices = []string{choice(answer)}
+	seen := map[string]bool{variant.Choices[0]: true}
+	for _, d := range t.Distractors {
+		v, err := evalExpr(d, vars)
+		if err != nil {
+			return q, fmt.Errorf("distractor %q: %w", d, err)
+		}
+		c := choice(v)
+		if seen[c] {
+			return q, fmt.Errorf("distractor %q gives %s, the same as another choice", d, c)
+		}
+		seen[c] = true
+		variant.Choices = append(variant.Choices, c)
+	}
+
+	// Plain variables keep their drawn form; computed values are rounded
+	// like the choices
+	var fillErr error
+	fillText := func(text string) string {
+		return placeholderPattern.ReplaceAllStringFunc(text, func(m string) string {
+			src := strings.TrimSpace(m[1 : len(m)-1])
+			v, err := evalExpr(src, vars)
+			if err != nil {
+				fillErr = fmt.Errorf("placeholder %s: %w", m, err)
+				return m
+			}
+			if _, plain := t.Variables[src]; plain {
+				return formatNumber(v)
+			}
+			return format(v)
+		})
+	}
+	variant.Question = fillText(q.Question)
+	variant.Explanation = fillText(q.Explanation)
+	variant.Hint = fillText(q.Hint)
+	return variant, fillErr
+}
+
+// instantiate returns the question to serve in the session: the variant for
+// the session's seed if it is a template, or the question itself. A template
+// without a valid variant has no choices to answer, so it cannot be served.
+func (s *QuizSession) instantiate(q Question) (Question, error) {
+	if q.Template == nil {
+		return q, nil
+	}
+	variant, err := generateVariant(q, variantSeed(s.Seed, q.ID))
+	if err != nil {
+		return q, fmt.Errorf("question %d: %w", q.ID, err)
+	}
+	return variant, nil
+}
+
+// instantiateAll replaces every template question with its variant
+func (s *QuizSession) instantiateAll(questions []Question) ([]Question, error) {
+	out := make([]Question, len(questions))
+	for i, q := range questions {
+		var err error
+		if out[i], err = s.instantiate(q); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// formatNumber shows a value without trailing zeros or float noise
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(roundTo(v, 9), 'f', -1, 64)
+}
+
+// roundTo rounds v to the given number of decimal places
+func roundTo(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
+
+// templateFuncs are the functions template expressions may call
+var templateFuncs = map[string]func(args []float64) (float64, error){
+	"abs":   unaryFunc(math.Abs),
+	"sqrt":  unaryFunc(math.Sqrt),
+	"floor": unaryFunc(math.Floor),
+	"ceil":  unaryFunc(math.Ceil),
+	"round": func(args []float64) (float64, error) {
+		switch len(args) {
+		case 1:
+			return math.Round(args[0]), nil
+		case 2:
+			return roundTo(args[0], int(args[1])), nil
+		}
+		return 0, errors.New("round takes 1 or 2 arguments")
+	},
+	"min": func(args []float64) (float64, error) {
+		if len(args) == 0 {
+			return 0, errors.New("min needs an argument")
+		}
+		m := args[0]
+		for _, a := range args[1:] {
+			m = math.Min(m, a)
+		}
+		return m, nil
+	},
+	"max": func(args []float64) (float64, error) {
+		if len(args) == 0 {
+			return 0, errors.New("max needs an argument")
+		}
+		m := args[0]
+		for _, a := range args[1:] {
+			m = math.Max(m, a)
+		}
+		return m, nil
+	},
+}
+
+// unaryFunc adapts a one-argument math function
+func unaryFunc(f func(float64) float64) func([]float64) (float64, error) {
+	return func(args []float64) (float64, error) {
+		if len(args) != 1 {
+			return 0, errors.New("takes 1 argument")
+		}
+		return f(args[0]), nil
+	}
+}
+
+// expr is a parsed template expression and the variables it uses
+type expr struct {
+	eval  func(vars map[string]float64) (float64, error)
+	names []string
+}
+
+// evalExpr parses and evaluates an expression. Results that are not finite,
+// such as a division by zero, are errors.
+func evalExpr(src string, vars map[string]float64) (float64, error) {
+	e, err := parseExpr(src)
+	if err != nil {
+		return 0, err
+	}
+	v, err := e.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("%s is not a finite number", src)
+	}
+	return v, nil
+}
+
+// parseExpr parses arithmetic over numbers, variables and templateFuncs with
+// + - * / % ^ and parentheses
+func parseExpr(src string) (*expr, error) {
+	p := &exprParser{src: src}
+	e := &expr{}
+	p.names = &e.names
+	eval, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q", p.src[p.pos:])
+	}
+	e.eval = eval
+	return e, nil
+}
+
+type evalFunc = func(vars map[string]float64) (float64, error)
+
+// exprParser is a recursive descent parser for template expressions
+type exprParser struct {
+	src   string
+	pos   int
+	names *[]string
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// accept consumes c if it is the next character
+func (p *exprParser) accept(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// binary combines two operands with an operator
+func binary(op byte, left, right evalFunc) evalFunc {
+	return func(vars map[string]float64) (float64, error) {
+		a, err := left(vars)
+		if err != nil {
+			return 0, err
+		}
+		b, err := right(vars)
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '+':
+			return a + b, nil
+		case '-':
+			return a - b, nil
+		case '*':
+			return a * b, nil
+		case '/':
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a / b, nil
+		case '%':
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return math.Mod(a, b), nil
+		}
+		return math.Pow(a, b), nil
+	}
+}
+
+func (p *exprParser) parseSum() (evalFunc, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op byte
+		switch {
+		case p.accept('+'):
+			op = '+'
+		case p.accept('-'):
+			op = '-'
+		default:
+			return left, nil
+		}
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = binary(op, left, right)
+	}
+}
+
+func (p *exprParser) parseProduct() (evalFunc, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op byte
+		switch {
+		case p.accept('*'):
+			op = '*'
+		case p.accept('/'):
+			op = '/'
+		case p.accept('%'):
+			op = '%'
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binary(op, left, right)
+	}
+}
+
+func (p *exprParser) parseUnary() (evalFunc, error) {
+	if p.accept('-') {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(vars map[string]float64) (float64, error) {
+			v, err := operand(vars)
+			return -v, err
+		}, nil
+	}
+	return p.parsePower()
+}
+
+// parsePower parses exponentiation, which binds tighter than unary minus on
+// its left and is right associative
+func (p *exprParser) parsePower() (evalFunc, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept('^') {
+		return base, nil
+	}
+	exponent, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return binary('^', base, exponent), nil
+}
+
+func (p *exprParser) parsePrimary() (evalFunc, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, errors.New("unexpected end of expression")
+	}
+
+	if p.accept('(') {
+		inner, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(')') {
+			return nil, errors.New("missing )")
+		}
+		return inner, nil
+	}
+
+	start := p.pos
+	c := rune(p.src[p.pos])
+	switch {
+	case unicode.IsDigit(c) || c == '.':
+		for p.pos < len(p.src) && (unicode.IsDigit(rune(p.src[p.pos])) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", p.src[start:p.pos])
+		}
+		return func(map[string]float64) (float64, error) { return v, nil }, nil
+
+	case unicode.IsLetter(c):
+		for p.pos < len(p.src) && (unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos])) || p.src[p.pos] == '_') {
+			p.pos++
+		}
+		name := p.src[start:p.pos]
+		if p.accept('(') {
+			return p.parseCall(name)
+		}
+		*p.names = append(*p.names, name)
+		return func(vars map[string]float64) (float64, error) {
+			v, ok := vars[name]
+			if !ok {
+				return 0, fmt.Errorf("unknown variable %q", name)
+			}
+			return v, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", p.src[p.pos:])
+}
+
+// parseCall parses the arguments of a function call after its "("
+func (p *exprParser) parseCall(name string) (evalFunc, error) {
+	f, ok := templateFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+	var args []evalFunc
+	if !p.accept(')') {
+		for {
+			arg, err := p.parseSum()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.accept(')') {
+				break
+			}
+			if !p.accept(',') {
+				return nil, fmt.Errorf("expected , or ) in call to %s", name)
+			}
+		}
+	}
+	return func(vars map[string]float64) (float64, error) {
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			v, err := arg(vars)
+			if err != nil {
+				return 0, err
+			}
+			values[i] = v
+		}
+		v, err := f(values)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", name, err)
+		}
+		return v, nil
+	}, nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEvalExpr(t *testing.T) {
+	vars := map[string]float64{"a": 6, "b": 4}
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		{"a + b * 2", 14},
+		{"(a + b) * 2", 20},
+		{"a / b", 1.5},
+		{"a % b", 2},
+		{"-a ^ 2", -36},
+		{"2 ^ 3 ^ 2", 512},
+		{"round(a / b)", 2},
+		{"round(10 / 3, 2)", 3.33},
+		{"max(a, b, 9) - min(a, b)", 5},
+		{"sqrt(abs(-16)) + floor(1.7) + ceil(1.2)", 7},
+	}
+	for _, tt := range tests {
+		got, err := evalExpr(tt.expr, vars)
+		if err != nil {
+			t.Errorf("evalExpr(%q) returned error: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("evalExpr(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"a +", "(a", "a b", "c", "nope(a)", "a / (b - 4)", "sqrt(a, b)", "$"} {
+		if _, err := evalExpr(bad, vars); err == nil {
+			t.Errorf("evalExpr(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+// multiplyTemplate is a times-table drill used across the template tests
+func multiplyTemplate() Question {
+	lo, hi := 2.0, 12.0
+	return Question{
+		ID:          7,
+		Question:    "What is {a} × {b}?",
+		Explanation: "{a} × {b} = {answer}",
+		Template: &QuestionTemplate{
+			Variables: map[string]TemplateVariable{
+				"a": {Min: &lo, Max: &hi},
+				"b": {Values: []float64{3, 7, 9}},
+			},
+			Answer:      "a * b",
+			Distractors: []string{"a * b + a", "a * b - b", "a + b"},
+		},
+	}
+}
+
+func TestGenerateVariant(t *testing.T) {
+	q := multiplyTemplate()
+	numbers := make(map[string]bool)
+	for seed := int64(0); seed < 30; seed++ {
+		v, err := generateVariant(q, seed)
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		again, _ := generateVariant(q, seed)
+		if !reflect.DeepEqual(v, again) {
+			t.Fatalf("seed %d gave different variants:\n%#v\n%#v", seed, v, again)
+		}
+		if v.Template != nil || v.VariantSeed != seed {
+			t.Errorf("seed %d: variant has Template %v and VariantSeed %d", seed, v.Template, v.VariantSeed)
+		}
+		if len(v.Choices) != 4 {
+			t.Fatalf("seed %d: got %d choices, want 4", seed, len(v.Choices))
+		}
+
+		// The key is exactly a × b, read back from the question text
+		var a, b int
+		fields := strings.Fields(strings.TrimSuffix(v.Question, "?"))
+		a, _ = strconv.Atoi(fields[2])
+		b, _ = strconv.Atoi(fields[4])
+		if a < 2 || a > 12 || (b != 3 && b != 7 && b != 9) {
+			t.Errorf("seed %d: drew a=%d b=%d out of range in %q", seed, a, b, v.Question)
+		}
+		if want := strconv.Itoa(a * b); v.Choices[v.AnswerIndex] != want {
+			t.Errorf("seed %d: key %q for %q, want %s", seed, v.Choices[v.AnswerIndex], v.Question, want)
+		}
+		if want := fields[2] + " × " + fields[4] + " = " + strconv.Itoa(a*b); v.Explanation != want {
+			t.Errorf("seed %d: explanation %q, want %q", seed, v.Explanation, want)
+		}
+		numbers[v.Question] = true
+	}
+	if len(numbers) < 10 {
+		t.Errorf("30 seeds gave only %d different questions", len(numbers))
+	}
+}
+
+func TestGenerateVariantFormatting(t *testing.T) {
+	km := 1.25
+	two := 2
+	q := Question{
+		ID:       1,
+		Question: "How many metres is {km} km?",
+		Template: &QuestionTemplate{
+			Variables:   map[string]TemplateVariable{"km": {Min: &km, Max: &km}},
+			Answer:      "km * 1000",
+			Distractors: []string{"km * 100", "km / 3"},
+			Decimals:    &two,
+			Unit:        "m",
+		},
+	}
+	v, err := generateVariant(q, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Question != "How many metres is 1.25 km?" {
+		t.Errorf("question = %q", v.Question)
+	}
+	want := []string{"1250.00 m", "125.00 m", "0.42 m"}
+	got := append([]string(nil), v.Choices...)
+	if got[v.AnswerIndex] != want[0] {
+		t.Errorf("key = %q, want %q", got[v.AnswerIndex], want[0])
+	}
+	for _, w := range want {
+		found := false
+		for _, c := range got {
+			found = found || c == w
+		}
+		if !found {
+			t.Errorf("choices %q are missing %q", got, w)
+		}
+	}
+}
+
+func TestGenerateVariantRedraws(t *testing.T) {
+	zero, two := 0.0, 2.0
+	q := Question{
+		ID:       1,
+		Question: "What is 12 / {d}?",
+		Template: &QuestionTemplate{
+			Variables:   map[string]TemplateVariable{"d": {Min: &zero, Max: &two}},
+			Answer:      "12 / d",
+			Distractors: []string{"12 * d"},
+		},
+	}
+	// d=0 divides by zero and d=1 makes both choices 12; only d=2 works
+	for seed := int64(0); seed < 10; seed++ {
+		v, err := generateVariant(q, seed)
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		if v.Question != "What is 12 / 2?" {
+			t.Errorf("seed %d: got %q", seed, v.Question)
+		}
+	}
+
+	q.Template.Variables["d"] = TemplateVariable{Values: []float64{1}}
+	if _, err := generateVariant(q, 1); err == nil {
+		t.Error("a template that can never give distinct choices should fail")
+	}
+}
+
+func TestLintTemplate(t *testing.T) {
+	if issues := lintQuestion(multiplyTemplate()); len(issues) != 0 {
+		t.Errorf("valid template has issues: %v", issues)
+	}
+
+	tests := []struct {
+		name   string
+		change func(q *Question)
+		want   string
+	}{
+		{"choices", func(q *Question) { q.Choices = []string{"A", "B"} }, `remove "choices"`},
+		{"no distractors", func(q *Question) { q.Template.Distractors = nil }, "at least one distractor"},
+		{"unknown variable", func(q *Question) { q.Template.Answer = "a * c" }, `unknown variable "c"`},
+		{"answer uses answer", func(q *Question) { q.Template.Answer = "answer + 1" }, `unknown variable "answer"`},
+		{"bad placeholder", func(q *Question) { q.Question = "What is {a *}?" }, "placeholder"},
+		{"bad range", func(q *Question) { q.Template.Variables["a"] = TemplateVariable{} }, "needs min and max"},
+		{"too many steps", func(q *Question) {
+			lo, hi := 0.0, 1e10
+			q.Template.Variables["a"] = TemplateVariable{Min: &lo, Max: &hi, Step: 1e-9}
+		}, "use a larger step"},
+		{"never distinct", func(q *Question) { q.Template.Distractors = []string{"b * a"} }, "the same as another choice"},
+	}
+	for _, tt := range tests {
+		q := multiplyTemplate()
+		tt.change(&q)
+		issues := lintQuestion(q)
+		if len(issues) != 1 || issues[0].Rule != "template" || !strings.Contains(issues[0].Message, tt.want) {
+			t.Errorf("%s: got %v, want one template issue containing %q", tt.name, issues, tt.want)
+		}
+	}
+}
+
+func TestLoadTemplateQuestion(t *testing.T) {
+	data := `{"schema_version": 2, "questions": [
+		{"id": 1, "question": "What is {a} + {b}?", "explanation": "{a} + {b} = {answer}",
+		 "template": {"variables": {"a": {"min": 1, "max": 9}, "b": {"min": 10, "max": 90, "step": 10}},
+		              "answer": "a + b", "distractors": ["a + b + 1", "a + b - 10"]}}
+	]}`
+	questions, issues, err := lintQuestionBank([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("template bank has issues: %v", issues)
+	}
+	if len(questions) != 1 || questions[0].Template == nil || questions[0].Template.Variables["b"].Step != 10 {
+		t.Fatalf("template was not decoded: %#v", questions)
+	}
+}
+
+func TestSessionVariants(t *testing.T) {
+	q := multiplyTemplate()
+	plain := Question{ID: 8, Question: "Plain?", Choices: []string{"A", "B"}}
+	s := &QuizSession{Seed: 42}
+	served, err := s.instantiateAll([]Question{q, plain})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(served[1], plain) {
+		t.Errorf("plain question changed: %#v", served[1])
+	}
+	if served[0].VariantSeed != variantSeed(42, q.ID) {
+		t.Errorf("VariantSeed = %d, want %d", served[0].VariantSeed, variantSeed(42, q.ID))
+	}
+
+	// The variant is reproducible from the seed recorded with the answer
+	again, err := generateVariant(q, served[0].VariantSeed)
+	if err != nil || !reflect.DeepEqual(again, served[0]) {
+		t.Errorf("regenerated variant %#v (err %v), want %#v", again, err, served[0])
+	}
+
+	// A template without a valid variant has no choices, so it must not be
+	// served in its place
+	broken := multiplyTemplate()
+	broken.Template.Answer = "a / (b - b)"
+	if served, err := s.instantiateAll([]Question{plain, broken}); err == nil {
+		t.Errorf("instantiateAll served %#v, want an error", served)
+	}
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// questionVersionsPath is the file the edit history of every question is
+// kept in
+var questionVersionsPath = "question_versions.json"
+
+// regradeLogPath is the JSON-lines audit trail of regrades
+var regradeLogPath = "regrades.jsonl"
+
+// versionsMux serializes updates to the edit history and guards
+// recordedQuestions
+var versionsMux sync.Mutex
+
+// recordedQuestions maps question IDs to the fingerprint of their latest
+// recorded revision, so loads only touch the edit history when something
+// changed. It is nil until the edit history has been read.
+var recordedQuestions map[int]string
+
+// QuestionRevision is one version of a question as it was first loaded
+type QuestionRevision struct {
+	Version  int       `json:"version"`
+	Recorded time.Time `json:"recorded"`
+	Question Question  `json:"question"`
+}
+
+// questionHistory maps question IDs to their revisions, oldest first
+type questionHistory map[int][]QuestionRevision
+
+// version returns the question's version, counting an unset one as 1
+func (q Question) version() int {
+	if q.Version < 1 {
+		return 1
+	}
+	return q.Version
+}
+
+// questionFingerprint identifies a question's content for change detection
+func questionFingerprint(q Question) string {
+	data, _ := json.Marshal(q)
+	return string(data)
+}
+
+// bumpVersion returns edited with its version set for replacing original:
+// one past the original's if the content changed and the editor did not
+// raise it, or the original's if nothing changed
+func bumpVersion(original, edited Question) Question {
+	a, b := original, edited
+	a.Version, b.Version = 0, 0
+	switch {
+	case questionFingerprint(a) == questionFingerprint(b):
+		edited.Version = original.Version
+	case edited.version() <= original.version():
+		edited.Version = original.version() + 1
+	}
+	return edited
+}
+
+// recordQuestionVersions adds a revision to the edit history for every
+// question that is new or has changed since it was last recorded. A change
+// without a version bump is recorded too, but logged, since answers to the
+// earlier text cannot be told apart for regrading.
+func recordQuestionVersions(questions []Question, now time.Time) error {
+	versionsMux.Lock()
+	defer versionsMux.Unlock()
+
+	var history questionHistory
+	if recordedQuestions == nil {
+		history = make(questionHistory)
+		if err := readJSONFile(questionVersionsPath, &history); err != nil {
+			return err
+		}
+		recordedQuestions = make(map[int]string, len(history))
+		for id, revisions := range history {
+			recordedQuestions[id] = questionFingerprint(revisions[len(revisions)-1].Question)
+		}
+	}
+
+	changed := make(map[int]string)
+	for _, q := range questions {
+		if fp := questionFingerprint(q); recordedQuestions[q.ID] != fp {
+			changed[q.ID] = fp
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	if history == nil {
+		history = make(questionHistory)
+		if err := readJSONFile(questionVersionsPath, &history); err != nil {
+			return err
+		}
+	}
+	for _, q := range questions {
+		if _, ok := changed[q.ID]; !ok {
+			continue
+		}
+		revisions := history[q.ID]
+		if n := len(revisions); n > 0 && revisions[n-1].Version >= q.version() {
+			log.Printf("Warning: question %d changed without a version bump; raise \"version\" so earlier answers can be regraded", q.ID)
+		}
+		history[q.ID] = append(revisions, QuestionRevision{Version: q.version(), Recorded: now, Question: q})
+	}
+	if err := writeJSONFile(questionVersionsPath, history); err != nil {
+		return err
+	}
+	for id, fp := range changed {
+		recordedQuestions[id] = fp
+	}
+	return nil
+}
+
+// loadQuestionRevisions returns the edit history of a question, oldest first
+func loadQuestionRevisions(id int) ([]QuestionRevision, error) {
+	versionsMux.Lock()
+	defer versionsMux.Unlock()
+
+	history := make(questionHistory)
+	if err := readJSONFile(questionVersionsPath, &history); err != nil {
+		return nil, err
+	}
+	return history[id], nil
+}
+
+// RegradeRecord is the audit entry for one regrade of a question
+type RegradeRecord struct {
+	Time       time.Time `json:"time"`
+	Admin      string    `json:"admin"`
+	QuestionID int       `json:"question_id"`
+	Version    int       `json:"version"`
+	// Answers counts the answers to earlier versions that were regraded, and
+	// Changed those whose correctness changed
+	Answers  int              `json:"answers"`
+	Changed  int              `json:"changed"`
+	Sessions []SessionRegrade `json:"sessions,omitempty"`
+	// Attempts counts the stored attempt scores that were corrected
+	Attempts int  `json:"attempts"`
+	DryRun   bool `json:"dry_run,omitempty"`
+}
+
+// SessionRegrade is the score change a regrade made to one session
+type SessionRegrade struct {
+	SessionID string `json:"session_id"`
+	PlayerID  string `json:"player_id,omitempty"`
+	Delta     int    `json:"delta"`
+}
+
+// regradeAnswer reports whether a choice is correct under the current
+// version of a question. Template answers are checked against the variant
+// regenerated from their recorded seed.
+func regradeAnswer(q Question, choice int, seed int64) (bool, error) {
+	if q.Template != nil {
+		variant, err := generateVariant(q, seed)
+		if err != nil {
+			return false, err
+		}
+		return choice == variant.AnswerIndex, nil
+	}
+	if err := q.validate(); err != nil {
+		return false, err
+	}
+	return choice == q.AnswerIndex, nil
+}
+
+// regradeQuestion grades every recorded answer to an earlier version of q
+// against q, correcting the answer history, the scores of sessions still in
+// memory and the stored attempt scores, and appends the result to the
+// regrade log. A dry run only reports what would change.
+func regradeQuestion(q Question, admin string, dryRun bool, now time.Time) (RegradeRecord, error) {
+	rec := RegradeRecord{Time: now, Admin: admin, QuestionID: q.ID, Version: q.version(), DryRun: dryRun}
+
+	defs, err := loadQuizDefinitions()
+	if err != nil {
+		return rec, err
+	}
+
+	historyMux.Lock()
+	defer historyMux.Unlock()
+
+	events, err := readAnswerHistory(answerHistoryPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return rec, err
+	}
+
+	sessionMux.Lock()
+	defer sessionMux.Unlock()
+
+	deltas := make(map[string]int)
+	regraded := make(map[string]bool)
+	for i := range events {
+		e := &events[i]
+		// Answers recorded before questions had versions were to version 1
+		served := e.QuestionVersion
+		if served < 1 {
+			served = 1
+		}
+		if e.QuestionID != q.ID || served >= rec.Version || e.GradedVersion >= rec.Version {
+			continue
+		}
+		correct, err := regradeAnswer(q, e.Choice, e.VariantSeed)
+		if err != nil {
+			return rec, fmt.Errorf("question %d: %w", q.ID, err)
+		}
+		rec.Answers++
+		regraded[e.SessionID] = true
+		e.GradedVersion = rec.Version
+		if correct == e.Correct {
+			continue
+		}
+		rec.Changed++
+
+		// Sessions still in memory know the rules they were scored under
+		rules := defaultScoringRules()
+		if session, ok := sessions[e.SessionID]; ok {
+			rules = session.Scoring
+		} else if def, ok := findQuizDefinition(defs, e.QuizSlug); ok {
+			rules = def.Scoring
+		}
+		delta := rules.points(correct, e.Confidence, e.Lifelines) - rules.points(e.Correct, e.Confidence, e.Lifelines)
+		if _, seen := deltas[e.SessionID]; !seen {
+			rec.Sessions = append(rec.Sessions, SessionRegrade{SessionID: e.SessionID, PlayerID: e.PlayerID})
+		}
+		deltas[e.SessionID] += delta
+		e.Correct = correct
+	}
+	for i := range rec.Sessions {
+		rec.Sessions[i].Delta = deltas[rec.Sessions[i].SessionID]
+	}
+	if dryRun {
+		return rec, nil
+	}
+
+	if rec.Answers > 0 {
+		if err := writeAnswerHistory(answerHistoryPath, events); err != nil {
+			return rec, err
+		}
+	}
+	for id, session := range sessions {
+		session.regrade(q, regraded[id])
+	}
+	if rec.Answers == 0 {
+		return rec, nil
+	}
+	if rec.Attempts, err = adjustAttemptScores(deltas); err != nil {
+		return rec, err
+	}
+	if err := appendJSONLine(regradeLogPath, rec); err != nil {
+		return rec, err
+	}
+	return rec, nil
+}
+
+// regrade replaces the session's copies of earlier versions of q with q, so
+// its results show the current key and explanation and its remaining
+// questions are graded against them. Answers whose history was regraded
+// (answered is true) are corrected along with the score; answers not yet in
+// the history keep their grade for the regrade that finds them there.
+// Callers must hold sessionMux.
+func (s *QuizSession) regrade(q Question, answered bool) {
+	current := func(old Question) (Question, bool) {
+		if old.ID != q.ID || old.version() >= q.version() {
+			return old, false
+		}
+		if q.Template == nil {
+			return q, true
+		}
+		variant, err := generateVariant(q, old.VariantSeed)
+		if err != nil {
+			log.Printf("Error regenerating variant of question %d: %v", q.ID, err)
+			return old, false
+		}
+		return variant, true
+	}
+
+	for i := range s.Questions {
+		served, ok := current(s.Questions[i])
+		if !ok {
+			continue
+		}
+		if i < len(s.Answers) {
+			if !answered {
+				continue
+			}
+			if a := &s.Answers[i]; !a.Skipped {
+				correct := a.Choice == served.AnswerIndex
+				points := s.Scoring.points(correct, a.Confidence, a.Lifelines)
+				s.Score += points - a.Points
+				a.Correct, a.Points = correct, points
+			}
+		}
+		s.Questions[i] = served
+	}
+	for i := range s.adaptivePool {
+		s.adaptivePool[i], _ = current(s.adaptivePool[i])
+	}
+	for name, old := range s.branchQuestions {
+		s.branchQuestions[name], _ = current(old)
+	}
+}
+
+// readRegradeLog loads every regrade from the regrade log
+func readRegradeLog() ([]RegradeRecord, error) {
+	f, err := os.Open(regradeLogPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []RegradeRecord
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec RegradeRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", regradeLogPath, line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// questionForm finds the bank question named by the request's "question"
+// parameter, an ID or "namespace:id", replying with an error and returning
+// false if there is none
+func questionForm(w http.ResponseWriter, r *http.Request) (Question, bool) {
+	id, err := parseQuestionRef(r.FormValue("question"))
+	if err != nil {
+		http.Error(w, "Invalid question ID", http.StatusBadRequest)
+		return Question{}, false
+	}
+	questions, err := loadQuestions()
+	if err != nil {
+		log.Printf("Error loading questions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return Question{}, false
+	}
+	for _, q := range questions {
+		if q.ID == id {
+			return q, true
+		}
+	}
+	http.Error(w, "Question not found", http.StatusNotFound)
+	return Question{}, false
+}
+
+// questionVersionsHandler handles GET /admin/versions?question=ID, returning
+// the question's edit history and regrades as JSON
+func questionVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q, ok := questionForm(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := loadQuestionRevisions(q.ID)
+	if err != nil {
+		log.Printf("Error loading question versions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	all, err := readRegradeLog()
+	if err != nil {
+		log.Printf("Error reading regrade log: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	var regrades []RegradeRecord
+	for _, rec := range all {
+		if rec.QuestionID == q.ID {
+			regrades = append(regrades, rec)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(struct {
+		QuestionID int                `json:"question_id"`
+		Version    int                `json:"version"`
+		Revisions  []QuestionRevision `json:"revisions"`
+		Regrades   []RegradeRecord    `json:"regrades"`
+		// RegradeToken authorizes a POST to /admin/regrade
+		RegradeToken string `json:"regrade_token"`
+	}{q.ID, q.version(), revisions, regrades, adminFormToken(r, "/admin/regrade")})
+	if err != nil {
+		log.Printf("Error encoding question versions: %v", err)
+	}
+}
+
+// regradeHandler handles POST /admin/regrade, regrading the answers to
+// earlier versions of the "question" form value against the current bank.
+// With dry_run=1 it only reports what would change.
+func regradeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q, ok := questionForm(w, r)
+	if !ok {
+		return
+	}
+
+	admin, _, _ := r.BasicAuth()
+	dryRun, _ := strconv.ParseBool(r.FormValue("dry_run"))
+	rec, err := regradeQuestion(q, admin, dryRun, time.Now())
+	if err != nil {
+		log.Printf("Error regrading question %d: %v", q.ID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !dryRun {
+		log.Printf("Question %d regraded to version %d by %s: %d answers, %d changed", q.ID, rec.Version, admin, rec.Answers, rec.Changed)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rec); err != nil {
+		log.Printf("Error encoding regrade: %v", err)
+	}
+}
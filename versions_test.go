@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTempVersionStores points the answer history, attempts, edit history and
+// regrade log at a fresh directory for one test
+func useTempVersionStores(t *testing.T) {
+	dir := t.TempDir()
+	paths := []*string{&answerHistoryPath, &attemptsPath, &questionVersionsPath, &regradeLogPath}
+	saved := make([]string, len(paths))
+	for i, p := range paths {
+		saved[i] = *p
+		*p = filepath.Join(dir, filepath.Base(*p))
+	}
+	recordedQuestions = nil
+	t.Cleanup(func() {
+		for i, p := range paths {
+			*p = saved[i]
+		}
+		recordedQuestions = nil
+	})
+}
+
+func TestBumpVersion(t *testing.T) {
+	original := Question{ID: 1, Question: "Q?", Choices: []string{"A", "B"}, Version: 2}
+
+	if got := bumpVersion(original, Question{ID: 1, Question: "Q?", Choices: []string{"A", "B"}}); got.Version != 2 {
+		t.Errorf("unchanged question got version %d, want 2", got.Version)
+	}
+	edited := original
+	edited.AnswerIndex = 1
+	if got := bumpVersion(original, edited); got.Version != 3 {
+		t.Errorf("edited question got version %d, want 3", got.Version)
+	}
+	edited.Version = 7
+	if got := bumpVersion(original, edited); got.Version != 7 {
+		t.Errorf("explicitly bumped question got version %d, want 7", got.Version)
+	}
+}
+
+func TestRecordQuestionVersions(t *testing.T) {
+	useTempVersionStores(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := Question{ID: 1, Question: "Q?", Choices: []string{"A", "B"}}
+
+	record := func(q Question) {
+		t.Helper()
+		if err := recordQuestionVersions([]Question{q}, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	record(q)
+	record(q)
+	q.AnswerIndex, q.Version = 1, 2
+	record(q)
+
+	// A fresh process picks up where the file left off
+	recordedQuestions = nil
+	record(q)
+
+	revisions, err := loadQuestionRevisions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("got %d revisions, want 2: %+v", len(revisions), revisions)
+	}
+	if revisions[0].Version != 1 || revisions[0].Question.AnswerIndex != 0 || revisions[1].Version != 2 || revisions[1].Question.AnswerIndex != 1 {
+		t.Errorf("revisions = %+v", revisions)
+	}
+}
+
+func TestRegradeQuestion(t *testing.T) {
+	useTempVersionStores(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Version 1 wrongly keyed choice 0; version 2 corrects it to choice 1
+	fixed := Question{ID: 5, Question: "Q?", Choices: []string{"A", "B"}, AnswerIndex: 1, Version: 2}
+	live := newTestSession(t, []Question{{ID: 5, Question: "Q?", Choices: []string{"A", "B"}}})
+	live.Answers = []Answer{{QuestionID: 5, Choice: 1, Points: 0, QuestionVersion: 1}}
+	live.Score = 0
+
+	events := []AnswerEvent{
+		{SessionID: live.ID, QuestionID: 5, Choice: 1, Correct: false, QuestionVersion: 1},
+		{SessionID: "old", PlayerID: "p1", QuestionID: 5, Choice: 0, Correct: true},
+		{SessionID: "old", PlayerID: "p1", QuestionID: 6, Choice: 0, Correct: true},
+		{SessionID: "new", QuestionID: 5, Choice: 1, Correct: true, QuestionVersion: 2},
+	}
+	for _, e := range events {
+		if err := appendAnswerEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	attempts := attemptLog{"p1": {"": {{SessionID: "old", Finished: true, Score: 2, MaxScore: 3}}}}
+	if err := writeJSONFile(attemptsPath, attempts); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dry run reports the changes without making them
+	rec, err := regradeQuestion(fixed, "admin", true, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Answers != 2 || rec.Changed != 2 || len(rec.Sessions) != 2 {
+		t.Errorf("dry run = %+v, want 2 answers changed in 2 sessions", rec)
+	}
+	if live.Score != 0 {
+		t.Error("dry run changed a live session")
+	}
+	if _, err := os.Stat(regradeLogPath); err == nil {
+		t.Error("dry run wrote to the regrade log")
+	}
+
+	rec, err = regradeQuestion(fixed, "admin", false, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SessionRegrade{{SessionID: live.ID, Delta: 1}, {SessionID: "old", PlayerID: "p1", Delta: -1}}
+	if len(rec.Sessions) != 2 || rec.Sessions[0] != want[0] || rec.Sessions[1] != want[1] || rec.Attempts != 1 {
+		t.Errorf("regrade = %+v, want sessions %+v and 1 attempt", rec, want)
+	}
+
+	if live.Score != 1 || !live.Answers[0].Correct || live.Answers[0].Points != 1 {
+		t.Errorf("live session score %d, answer %+v", live.Score, live.Answers[0])
+	}
+	if q := live.Questions[0]; q.AnswerIndex != 1 || q.version() != 2 {
+		t.Errorf("live session still serves %+v, want version 2", q)
+	}
+	got, err := readAnswerHistory(answerHistoryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got[0].Correct || got[1].Correct || got[0].GradedVersion != 2 || got[2].GradedVersion != 0 || got[0].QuestionVersion != 1 {
+		t.Errorf("history after regrade = %+v", got)
+	}
+	if records, _ := loadAttempts("p1", ""); records[0].Score != 1 {
+		t.Errorf("attempt score = %d, want 1", records[0].Score)
+	}
+
+	// Regrading again finds nothing left to do
+	if rec, err := regradeQuestion(fixed, "admin", false, now); err != nil || rec.Answers != 0 {
+		t.Errorf("second regrade = %+v, %v", rec, err)
+	}
+	if log, err := readRegradeLog(); err != nil || len(log) != 1 || log[0].Changed != 2 {
+		t.Errorf("regrade log = %+v, %v", log, err)
+	}
+}
+
+func TestRegradeHandler(t *testing.T) {
+	useTempVersionStores(t)
+	bank := filepath.Join(t.TempDir(), "questions.json")
+	if err := writeQuestionBank(bank, []Question{{ID: 5, Question: "Q?", Choices: []string{"A", "B"}, AnswerIndex: 1, Version: 2, Explanation: "B"}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("QUESTIONS_PATH", bank)
+	if err := appendAnswerEvent(AnswerEvent{SessionID: "s", QuestionID: 5, Choice: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/regrade", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("admin", "secret")
+		rr := httptest.NewRecorder()
+		regradeHandler(rr, req)
+		return rr
+	}
+	if rr := post(url.Values{"question": {"9"}}); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown question: got %d, want 404", rr.Code)
+	}
+
+	rr := post(url.Values{"question": {"5"}})
+	var rec RegradeRecord
+	if err := json.NewDecoder(rr.Body).Decode(&rec); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("got %d: %v", rr.Code, err)
+	}
+	if rec.Admin != "admin" || rec.Version != 2 || rec.Changed != 1 {
+		t.Errorf("regrade = %+v", rec)
+	}
+
+	rr = httptest.NewRecorder()
+	questionVersionsHandler(rr, httptest.NewRequest(http.MethodGet, "/admin/versions?question=5", nil))
+	var history struct {
+		Revisions []QuestionRevision `json:"revisions"`
+		Regrades  []RegradeRecord    `json:"regrades"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Revisions) != 1 || history.Revisions[0].Version != 2 || len(history.Regrades) != 1 {
+		t.Errorf("versions = %+v", history)
+	}
+}
+
+func TestRegradeLiveSessions(t *testing.T) {
+	useTempVersionStores(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	old := Question{ID: 5, Question: "Q?", Choices: []string{"A", "B"}, Explanation: "A"}
+	fixed := Question{ID: 5, Question: "Q?", Choices: []string{"A", "B"}, AnswerIndex: 1, Explanation: "B", Version: 2}
+	other := Question{ID: 6, Question: "Other?", Choices: []string{"A", "B"}}
+
+	// One session has yet to reach the question; another answered it but
+	// its answer has not reached the history yet
+	waiting := newTestSession(t, []Question{other, old})
+	unrecorded := newTestSession(t, []Question{old})
+	unrecorded.Answers = []Answer{{QuestionID: 5, Choice: 0, Correct: true, Points: 1}}
+	unrecorded.Score = 1
+
+	if _, err := regradeQuestion(fixed, "admin", false, now); err != nil {
+		t.Fatal(err)
+	}
+	if q := waiting.Questions[1]; q.AnswerIndex != 1 || q.Explanation != "B" {
+		t.Errorf("waiting session will serve %+v, want version 2", q)
+	}
+	if unrecorded.Score != 1 || unrecorded.Questions[0].version() != 1 {
+		t.Errorf("unrecorded answer was regraded before its history: score %d, question %+v", unrecorded.Score, unrecorded.Questions[0])
+	}
+}
+
+func TestRegradeBeforeAttemptIsRecorded(t *testing.T) {
+	useTempVersionStores(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fixed := Question{ID: 5, Question: "Q?", Choices: []string{"A", "B"}, AnswerIndex: 1, Version: 2}
+
+	session := newTestSession(t, []Question{{ID: 5, Question: "Q?", Choices: []string{"A", "B"}}})
+	session.PlayerID, session.QuizSlug, session.Attempt = "p1", "final", 1
+	if _, err := startAttempt("p1", "final", session.ID, 1, now); err != nil {
+		t.Fatal(err)
+	}
+	session.Answers = []Answer{{QuestionID: 5, Choice: 1, QuestionVersion: 1}}
+	session.Current = 1
+	if err := appendAnswerEvent(AnswerEvent{SessionID: session.ID, PlayerID: "p1", QuestionID: 5, Choice: 1, QuestionVersion: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The session finishes and captures its attempt, then a regrade runs
+	// before the attempt is saved
+	sessionMux.Lock()
+	attempt := session.completedAttempt()
+	sessionMux.Unlock()
+	if _, err := regradeQuestion(fixed, "admin", false, now); err != nil {
+		t.Fatal(err)
+	}
+	recordAttempt(session, attempt)
+
+	if records, _ := loadAttempts("p1", "final"); len(records) != 1 || !records[0].Finished || records[0].Score != 1 {
+		t.Errorf("attempt = %+v, want the regraded score 1", records)
+	}
+}
+
+func TestRegradeDroppingChoices(t *testing.T) {
+	useTempVersionStores(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fixed := Question{ID: 5, Question: "Q?", Choices: []string{"A", "B", "C"}, Version: 2}
+
+	// The player picked the distractor the new version removes
+	session := newTestSession(t, []Question{{ID: 5, Question: "Q?", Choices: []string{"A", "B", "C", "D"}, AnswerIndex: 3}})
+	session.Answers = []Answer{{QuestionID: 5, Choice: 3, Correct: true, Points: 1, QuestionVersion: 1}}
+	session.Score = 1
+	session.Current = 1
+	if err := appendAnswerEvent(AnswerEvent{SessionID: session.ID, QuestionID: 5, Choice: 3, Correct: true, QuestionVersion: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := regradeQuestion(fixed, "admin", false, now); err != nil {
+		t.Fatal(err)
+	}
+
+	sessionMux.Lock()
+	results := buildResults(session)
+	sessionMux.Unlock()
+	item := results.Items[0]
+	if session.Score != 0 || item.IsCorrect || item.ChosenIndex != 3 || item.ChosenAnswer != "" || item.CorrectAnswer != "A" {
+		t.Errorf("score %d, result %+v", session.Score, item)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlKeyPattern matches the field names allowed in a YAML question record
+var yamlKeyPattern = regexp.MustCompile(`^[a-z_]+$`)
+
+// parseYAML reads questions from the subset of YAML question authors need: a
+// top-level list of mappings whose values are plain or quoted scalars, flow
+// lists like [a, b], or block lists of scalars. Anchors, block scalars and
+// nested mappings are not supported.
+//
+//	# questions.yaml
+//	- question: What is the capital of France?
+//	  choices:
+//	    - London
+//	    - Paris
+//	  answer_index: 1
+//	  tags: [geo, europe]
+func parseYAML(r io.Reader) ([]importedQuestion, []error) {
+	var records []record
+	var errs []error
+
+	var current *record
+	itemIndent, keyIndent := -1, -1
+	var listKey string
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := stripYAMLComment(scanner.Text())
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if line == 1 && text == "---" {
+			continue
+		}
+		trimmed := strings.TrimLeft(text, " ")
+		indent := len(text) - len(trimmed)
+		if strings.HasPrefix(trimmed, "\t") {
+			errs = append(errs, lineError{line, errors.New("indent with spaces, not tabs")})
+			continue
+		}
+
+		isItem := trimmed == "-" || strings.HasPrefix(trimmed, "- ")
+		switch {
+		case isItem && listKey != "" && indent > itemIndent:
+			// An element of the block list under the last key
+			value, err := parseYAMLScalar(strings.TrimSpace(trimmed[1:]))
+			if err != nil {
+				errs = append(errs, lineError{line, err})
+				continue
+			}
+			f := current.Fields[listKey]
+			f.Values = append(f.Values, value)
+			current.Fields[listKey] = f
+
+		case isItem && (itemIndent == -1 || indent == itemIndent):
+			// A new question; its first key may follow the dash
+			records = append(records, record{Line: line, Fields: make(map[string]field)})
+			current = &records[len(records)-1]
+			itemIndent, listKey = indent, ""
+			rest := strings.TrimLeft(trimmed[1:], " ")
+			keyIndent = indent + len(trimmed) - len(rest)
+			if rest != "" {
+				listKey = addYAMLField(current, rest, line, &errs)
+			}
+
+		case isItem:
+			errs = append(errs, lineError{line, errors.New("list item is not aligned with the questions above it")})
+
+		case current == nil:
+			errs = append(errs, lineError{line, errors.New("expected a list of questions starting with \"- \"")})
+
+		case indent != keyIndent:
+			errs = append(errs, lineError{line, fmt.Errorf("field is indented %d spaces, want %d", indent, keyIndent)})
+
+		default:
+			listKey = addYAMLField(current, trimmed, line, &errs)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	var questions []importedQuestion
+	for _, rec := range records {
+		q, qerrs := questionFromRecord(rec)
+		questions = append(questions, q)
+		errs = append(errs, qerrs...)
+	}
+	return questions, errs
+}
+
+// addYAMLField parses a "key: value" line into the record. It returns the key
+// when the value is empty, meaning a block list follows.
+func addYAMLField(rec *record, text string, line int, errs *[]error) string {
+	key, value, ok := strings.Cut(text, ":")
+	if !ok || (value != "" && value[0] != ' ') || !yamlKeyPattern.MatchString(key) {
+		*errs = append(*errs, lineError{line, fmt.Errorf("expected \"key: value\", got %q", text)})
+		return ""
+	}
+	if _, dup := rec.Fields[key]; dup {
+		*errs = append(*errs, lineError{line, fmt.Errorf("duplicate field %q", key)})
+		return ""
+	}
+
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "":
+		rec.Fields[key] = field{Line: line, IsList: true}
+		return key
+	case strings.HasPrefix(value, "["):
+		values, err := parseYAMLFlowList(value)
+		if err != nil {
+			*errs = append(*errs, lineError{line, err})
+			return ""
+		}
+		rec.Fields[key] = field{Line: line, Values: values, IsList: true}
+	case strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+		*errs = append(*errs, lineError{line, errors.New("block scalars are not supported; use a quoted string")})
+	default:
+		s, err := parseYAMLScalar(value)
+		if err != nil {
+			*errs = append(*errs, lineError{line, err})
+			return ""
+		}
+		rec.Fields[key] = field{Line: line, Values: []string{s}}
+	}
+	return ""
+}
+
+// parseYAMLScalar returns the value of a plain, single-quoted or
+// double-quoted scalar
+func parseYAMLScalar(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("bad double-quoted string %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("unterminated single-quoted string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, nil
+}
+
+// parseYAMLFlowList splits a flow list such as [a, "b, c"] into its scalars
+func parseYAMLFlowList(s string) ([]string, error) {
+	if !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("unterminated list %s", s)
+	}
+	body := strings.TrimSpace(s[1 : len(s)-1])
+	if body == "" {
+		return nil, nil
+	}
+
+	var values []string
+	var quote byte
+	start := 0
+	for i := 0; i <= len(body); i++ {
+		if i < len(body) {
+			c := body[i]
+			switch {
+			case quote == '"' && c == '\\':
+				i++
+				continue
+			case quote != 0 && c == quote:
+				quote = 0
+				continue
+			case quote != 0:
+				continue
+			case (c == '"' || c == '\'') && strings.TrimSpace(body[start:i]) == "":
+				quote = c
+				continue
+			case c != ',':
+				continue
+			}
+		}
+		v, err := parseYAMLScalar(strings.TrimSpace(body[start:i]))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		start = i + 1
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated string in list %s", s)
+	}
+	return values, nil
+}
+
+// stripYAMLComment removes a trailing # comment that is not inside quotes
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" [,", line[i-1]) >= 0):
+			// Quotes only start a string at the beginning of a scalar
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' '):
+			return strings.TrimRight(line[:i], " ")
+		}
+	}
+	return strings.TrimRight(line, " ")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	input := `---
+# Geography questions
+- id: 3
+  question: "What is the capital of France?"  # quoted
+  choices:
+    - London
+    - 'Paris'
+  answer_index: 1
+  tags: [geo, "europe, west"]
+- question: It's 12 o'clock; which hand points up?
+  choices: [Hour, Minute, Both]
+  answer_index: 2
+  explanation: Both hands # point at twelve
+`
+	questions, errs := parseYAML(strings.NewReader(input))
+	if len(errs) > 0 {
+		t.Fatalf("parseYAML() returned errors: %v", errs)
+	}
+	if len(questions) != 2 {
+		t.Fatalf("got %d questions, want 2", len(questions))
+	}
+
+	q := questions[0]
+	if q.ID != 3 || q.Line != 3 || q.Question.Question != "What is the capital of France?" || q.AnswerIndex != 1 {
+		t.Errorf("first question = %+v", q)
+	}
+	if strings.Join(q.Choices, "|") != "London|Paris" || strings.Join(q.Tags, "|") != "geo|europe, west" {
+		t.Errorf("choices %v and tags %v", q.Choices, q.Tags)
+	}
+
+	q = questions[1]
+	if q.Question.Question != "It's 12 o'clock; which hand points up?" || len(q.Choices) != 3 || q.Explanation != "Both hands" {
+		t.Errorf("second question = %+v", q)
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	input := `- question: Broken?
+  choices: [A, B]
+   answer_index: 1
+  explanation: |
+  hint: "unterminated
+question: stray
+`
+	_, errs := parseYAML(strings.NewReader(input))
+	want := []string{
+		"line 3: field is indented 3 spaces, want 2",
+		"line 4: block scalars are not supported; use a quoted string",
+		`line 5: bad double-quoted string "unterminated`,
+		"line 6: field is indented 0 spaces, want 2",
+		"line 1: answer_index is missing",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %v, want %v", errs, want)
+	}
+	for i := range want {
+		if errs[i].Error() != want[i] {
+			t.Errorf("error %d = %q, want %q", i, errs[i], want[i])
+		}
+	}
+}